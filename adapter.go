@@ -0,0 +1,135 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// captureWriter 把标准 http.Handler 写的响应记录到 Context 上，由 HTTPServer 统一写回
+// 这样 web.Middleware 仍然可以看到并且修改标准 handler 的响应
+type captureWriter struct {
+	ctx         *Context
+	wroteHeader bool
+}
+
+func (w *captureWriter) Header() http.Header {
+	return w.ctx.Resp.Header()
+}
+
+func (w *captureWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.ctx.RespStatusCode = statusCode
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.ctx.RespData = append(w.ctx.RespData, data...)
+	return len(data), nil
+}
+
+// WrapHandler 把标准的 http.Handler 转换成 HandleFunc
+// 响应会先缓存在 RespData 和 RespStatusCode 里面，所以不适合长连接和流式输出
+func WrapHandler(handler http.Handler) HandleFunc {
+	return func(ctx *Context) {
+		w := &captureWriter{ctx: ctx}
+		handler.ServeHTTP(w, ctx.Req)
+		if !w.wroteHeader {
+			ctx.RespStatusCode = http.StatusOK
+		}
+	}
+}
+
+type contextKey struct{}
+
+// wrapCall WrapMiddleware 通过请求的 context 传给后面的 handler 的状态
+type wrapCall struct {
+	// ctx 后面的 handler 使用的 Context 副本
+	ctx *Context
+	// done 后面的 handler 已经执行完，为 1 的时候 ctx 不会再被修改
+	done int32
+}
+
+// WrapMiddleware 把 func(http.Handler) http.Handler 形式的标准 middleware 转换成 Middleware
+// 标准 middleware 对 Request 的修改会带到后面的 handler，对 ResponseWriter 的包装会作用在后面 handler 的响应上
+// 后面的 handler 使用的是 Context 的副本，所以 http.TimeoutHandler 这种在别的 goroutine 里面执行 handler 的 middleware 超时返回之后，
+// handler 继续执行也不会碰到已经回收的 Context；handler 在标准 middleware 返回之前执行完的时候，它对 Context 的修改会带回来
+func WrapMiddleware(m func(http.Handler) http.Handler) Middleware {
+	return func(next HandleFunc) HandleFunc {
+		handler := m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			call, ok := r.Context().Value(contextKey{}).(*wrapCall)
+			if !ok {
+				http.Error(w, "web: 标准 middleware 丢弃了请求的 context", http.StatusInternalServerError)
+				return
+			}
+			ctx := call.ctx
+			ctx.Req, ctx.Resp = r, w
+			next(ctx)
+			// 把后面的响应交给标准 middleware 包装过的 ResponseWriter
+			status, data := ctx.RespStatusCode, ctx.RespData
+			ctx.RespStatusCode, ctx.RespData = 0, nil
+			if status == 0 {
+				status = http.StatusOK
+			}
+			w.WriteHeader(status)
+			if len(data) > 0 {
+				_, _ = w.Write(data)
+			}
+			atomic.StoreInt32(&call.done, 1)
+		}))
+		return func(ctx *Context) {
+			call := &wrapCall{ctx: ctx.clone(nil)}
+			req := ctx.Req.WithContext(context.WithValue(ctx.Req.Context(), contextKey{}, call))
+			handler.ServeHTTP(&captureWriter{ctx: ctx}, req)
+			if atomic.LoadInt32(&call.done) == 0 {
+				return
+			}
+			// 响应已经通过 captureWriter 写到 ctx 上了，其余的状态从副本带回来，例如 Serve 设置的 PathParams 和 MatchedRoute
+			resp, status, data := ctx.Resp, ctx.RespStatusCode, ctx.RespData
+			*ctx = *call.ctx
+			ctx.Resp, ctx.RespStatusCode, ctx.RespData = resp, status, data
+		}
+	}
+}
+
+// Mount 把标准的 http.Handler 挂载到 prefix 下面，包括 prefix 本身和它下面的所有路径，支持所有的 http method
+// 转发之前会去掉 prefix，例如挂载在 /api 下面的子 HTTPServer 收到的是 /users 而不是 /api/users
+// 需要保留完整路径的 handler，例如 pprof，可以直接使用 h.Any("/debug/pprof/*", WrapHandler(handler))
+func (h *HTTPServer) Mount(prefix string, handler http.Handler, mds ...Middleware) {
+	h.Group("/").Mount(prefix, handler, mds...)
+}
+
+// Mount 挂载到分组下面，分组的 middleware 同样生效，见 HTTPServer.Mount
+func (g *RouteGroup) Mount(prefix string, handler http.Handler, mds ...Middleware) {
+	full := g.fullPath(prefix)
+	hf := WrapHandler(stripPrefix(full, handler))
+	star := prefix + "/*"
+	if prefix == "/" {
+		star = "/*"
+	}
+	g.Any(prefix, hf, mds...)
+	g.Any(star, hf, mds...)
+}
+
+// stripPrefix 和 http.StripPrefix 类似，区别是 prefix 本身会被转换成 /
+func stripPrefix(prefix string, handler http.Handler) http.Handler {
+	if prefix == "/" {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		r2.URL.RawPath = ""
+		handler.ServeHTTP(w, r2)
+	})
+}
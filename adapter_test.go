@@ -0,0 +1,195 @@
+package web
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPServer_Mount(t *testing.T) {
+	var mdsBuilder = func(i byte) Middleware {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				next(ctx)
+				ctx.Resp.Header().Add("X-Middleware", string(i))
+			}
+		}
+	}
+	sub := NewHttpServer()
+	sub.Get("/users", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("sub users")
+	})
+	std := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(r.Method + " " + r.URL.Path))
+	})
+
+	h := NewHttpServer(ServerWithMiddleware(mdsBuilder('g')))
+	h.Mount("/sub", sub)
+	h.Group("/api", mdsBuilder('a')).Mount("/std", std)
+	h.Get("/silent", WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		wantCode   int
+		wantResp   string
+		wantHeader []string
+	}{
+		{
+			name:       "sub server",
+			method:     http.MethodGet,
+			path:       "/sub/users",
+			wantCode:   http.StatusOK,
+			wantResp:   "sub users",
+			wantHeader: []string{"g"},
+		},
+		{
+			name:       "sub server not found",
+			method:     http.MethodGet,
+			path:       "/sub/orders",
+			wantCode:   http.StatusNotFound,
+			wantResp:   "NOT FOUND",
+			wantHeader: []string{"g"},
+		},
+		{
+			name:       "std handler prefix",
+			method:     http.MethodPost,
+			path:       "/api/std",
+			wantCode:   http.StatusAccepted,
+			wantResp:   "POST /",
+			wantHeader: []string{"a", "g"},
+		},
+		{
+			name:       "std handler trailing slash",
+			method:     http.MethodGet,
+			path:       "/api/std/",
+			wantCode:   http.StatusAccepted,
+			wantResp:   "GET /",
+			wantHeader: []string{"a", "g"},
+		},
+		{
+			name:       "std handler sub path",
+			method:     http.MethodDelete,
+			path:       "/api/std/a/b",
+			wantCode:   http.StatusAccepted,
+			wantResp:   "DELETE /a/b",
+			wantHeader: []string{"a", "g"},
+		},
+		{
+			name:       "handler without write",
+			method:     http.MethodGet,
+			path:       "/silent",
+			wantCode:   http.StatusOK,
+			wantHeader: []string{"g"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+			assert.Equal(t, tc.wantHeader, recorder.Header().Values("X-Middleware"))
+		})
+	}
+}
+
+func TestWrapMiddleware(t *testing.T) {
+	// 拦截请求的标准 middleware
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			r.Header.Set("X-User", "tom")
+			next.ServeHTTP(w, r)
+		})
+	}
+	// 包装 ResponseWriter 的标准 middleware
+	upper := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&upperWriter{ResponseWriter: w}, r)
+		})
+	}
+	h := NewHttpServer(ServerWithMiddleware(WrapMiddleware(auth)))
+	h.Get("/user", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("hello " + ctx.Req.Header.Get("X-User"))
+	}, WrapMiddleware(upper))
+
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(t, "unauthorized\n", recorder.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("Authorization", "token")
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "HELLO TOM", recorder.Body.String())
+}
+
+func TestWrapMiddleware_Async(t *testing.T) {
+	release, finished := make(chan struct{}), make(chan string)
+	timeout := WrapMiddleware(func(next http.Handler) http.Handler {
+		return http.TimeoutHandler(next, 20*time.Millisecond, "timeout")
+	})
+	// 标准 middleware 外面的 middleware 能看到 Serve 设置的 MatchedRoute
+	route := func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			ctx.Resp.Header().Set("X-Route", ctx.MatchedRoute)
+		}
+	}
+	h := NewHttpServer(ServerWithMiddleware(route, timeout))
+	h.Get("/slow/:id", func(ctx *Context) {
+		<-release
+		// 超时之后 handler 继续执行，用的是不会被回收的副本
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("slow")
+		finished <- ctx.PathParams["id"]
+	})
+	h.Get("/fast/:id", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("fast " + ctx.PathParams["id"])
+	})
+	serve := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	recorder := serve("/slow/1")
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, "timeout", recorder.Body.String())
+	assert.Equal(t, "", recorder.Header().Get("X-Route"))
+
+	// 超时的请求占用的 Context 已经回收，被后面的请求复用
+	for i := 0; i < 10; i++ {
+		recorder = serve("/fast/" + strconv.Itoa(i))
+		assert.Equal(t, "fast "+strconv.Itoa(i), recorder.Body.String())
+		assert.Equal(t, "/fast/:id", recorder.Header().Get("X-Route"))
+	}
+	close(release)
+	assert.Equal(t, "1", <-finished)
+}
+
+type upperWriter struct {
+	http.ResponseWriter
+}
+
+func (w *upperWriter) Write(data []byte) (int, error) {
+	return w.ResponseWriter.Write([]byte(strings.ToUpper(string(data))))
+}
@@ -0,0 +1,402 @@
+package web
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bindSources 按照优先级排列的数据来源，同一个字段有多个标签的时候使用第一个有值的来源
+var bindSources = []string{"path", "query", "form", "header", "cookie"}
+
+// FieldError 绑定某一个字段失败的原因
+type FieldError struct {
+	// Field 字段在结构体里面的路径，例如 Page.Size
+	Field string
+	// Source 值的来源，path、query、form、header、cookie，使用默认值的时候是 default
+	Source string
+	// Key 标签里面的名字
+	Key   string
+	Value string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("web: 字段 %s 绑定失败，%s [%s] 的值 %q 不合法: %v", e.Field, e.Source, e.Key, e.Value, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// BindErrors Bind 的时候所有绑定失败的字段
+type BindErrors []*FieldError
+
+func (es BindErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Bind 根据标签把请求里面的数据填到 dst 指向的结构体里面
+// 支持的标签：path:"id" query:"page" form:"name" header:"X-Token" cookie:"sid"
+// default:"10" 在请求里面没有值或者值是空字符串的时候使用，切片的默认值用逗号分隔
+// 只有 default 标签的字段在还是零值的时候使用默认值，已经有值的时候保留，例如先用 BindJson 填过的字段
+// layout:"2006-01-02" 指定 time.Time 的格式，默认是 time.RFC3339
+// 字段可以是基本类型、time.Time、time.Duration、实现了 encoding.TextUnmarshaler 的类型，以及它们的指针和切片
+// multipart 表单上传的文件可以用 form 标签绑定到 *multipart.FileHeader 或者 []*multipart.FileHeader
+// 没有标签的结构体字段会递归绑定，结构体指针只有在里面至少有一个字段有值的时候才会被创建
+// 某些字段的值不合法的时候，其余的字段仍然会被绑定，返回的 BindErrors 包含所有不合法的字段
+// 绑定成功之后使用 ServerWithValidator 设置的 Validator 校验，不通过的时候返回 Validator 的错误，TagValidator 返回的是 ValidationErrors
+func (c *Context) Bind(dst any) error {
+	if err := c.bind(dst, ""); err != nil {
+		return err
+	}
+	return c.validate(dst)
+}
+
+// bind only 不为空的时候只使用这一个来源，例如表单的 Codec 只绑定 form 标签
+func (c *Context) bind(dst any, only string) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("web: Bind 只支持非 nil 的结构体指针")
+	}
+	plan := bindPlanFor(v.Elem().Type())
+	if plan.hasForm && (only == "" || only == "form") {
+		if err := c.parseForm(); err != nil {
+			return fmt.Errorf("web: 解析表单失败 %w", err)
+		}
+	}
+	var errs BindErrors
+	c.bindStruct(v.Elem(), plan, only, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// parseForm multipart 的请求体使用 ParseMultipartForm，其余的使用 ParseForm
+func (c *Context) parseForm() error {
+	ct, _, _ := mime.ParseMediaType(c.Req.Header.Get("Content-Type"))
+	if ct == "multipart/form-data" {
+		return c.Req.ParseMultipartForm(defaultMultipartMemory)
+	}
+	return c.Req.ParseForm()
+}
+
+// defaultMultipartMemory 解析 multipart 表单的时候最多放在内存里面的大小，超过的部分写入临时文件
+const defaultMultipartMemory = 32 << 20
+
+// lookup 按照来源取出请求里面的值
+func (c *Context) lookup(source string, key string) ([]string, bool) {
+	switch source {
+	case "path":
+		val, ok := c.PathParams[key]
+		return []string{val}, ok
+	case "query":
+		vals, ok := c.queryValues()[key]
+		return vals, ok
+	case "form":
+		vals, ok := c.Req.Form[key]
+		return vals, ok
+	case "header":
+		vals := c.Req.Header.Values(key)
+		return vals, len(vals) > 0
+	case "cookie":
+		ck, err := c.Req.Cookie(key)
+		if err != nil {
+			return nil, false
+		}
+		return []string{ck.Value}, true
+	}
+	return nil, false
+}
+
+// bindStruct 返回是否至少有一个字段被赋值
+func (c *Context) bindStruct(v reflect.Value, plan *bindPlan, only string, errs *BindErrors) bool {
+	set := false
+	for _, f := range plan.fields {
+		fv := v.Field(f.index)
+		if f.nested != nil {
+			if fv.Kind() != reflect.Pointer {
+				set = c.bindStruct(fv, f.nested, only, errs) || set
+				continue
+			}
+			tmp := reflect.New(fv.Type().Elem())
+			if c.bindStruct(tmp.Elem(), f.nested, only, errs) {
+				fv.Set(tmp)
+				set = true
+			}
+			continue
+		}
+		if only != "" && !f.hasSource(only) {
+			continue
+		}
+		if f.file {
+			set = c.bindFile(fv, f) || set
+			continue
+		}
+		var source, key string
+		var vals []string
+		for _, s := range f.sources {
+			if only != "" && s.source != only {
+				continue
+			}
+			// 只有一个空字符串的时候当成没有值，例如 ?page=
+			if vs, ok := c.lookup(s.source, s.key); ok && !(len(vs) == 1 && vs[0] == "") {
+				source, key, vals = s.source, s.key, vs
+				break
+			}
+		}
+		if source == "" {
+			if !f.hasDefault || (len(f.sources) == 0 && !fv.IsZero()) {
+				continue
+			}
+			source, vals = "default", []string{f.def}
+			if len(f.sources) > 0 {
+				key = f.sources[0].key
+			}
+			if isSlice(fv.Type()) {
+				vals = strings.Split(f.def, ",")
+			}
+		}
+		if err := setField(fv, vals, f.layout); err != nil {
+			*errs = append(*errs, &FieldError{Field: f.name, Source: source, Key: key, Value: strings.Join(vals, ","), Err: err})
+			continue
+		}
+		set = true
+	}
+	return set
+}
+
+// bindFile 绑定 multipart 表单里面上传的文件
+func (c *Context) bindFile(v reflect.Value, f *bindField) bool {
+	if c.Req.MultipartForm == nil {
+		return false
+	}
+	for _, s := range f.sources {
+		fhs := c.Req.MultipartForm.File[s.key]
+		if s.source != "form" || len(fhs) == 0 {
+			continue
+		}
+		if v.Type() == fileHeaderType {
+			v.Set(reflect.ValueOf(fhs[0]))
+		} else {
+			v.Set(reflect.ValueOf(fhs))
+		}
+		return true
+	}
+	return false
+}
+
+var (
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// isSlice 需要多个值的切片，[]byte 和实现了 TextUnmarshaler 的切片类型，例如 net.IP，只需要一个值
+func isSlice(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 && !isScalar(t)
+}
+
+// setField 切片使用全部的值，其余的类型只使用第一个值
+func setField(v reflect.Value, vals []string, layout string) error {
+	if v.Kind() == reflect.Pointer {
+		nv := reflect.New(v.Type().Elem())
+		if err := setField(nv.Elem(), vals, layout); err != nil {
+			return err
+		}
+		v.Set(nv)
+		return nil
+	}
+	if isSlice(v.Type()) {
+		res := reflect.MakeSlice(v.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setField(res.Index(i), []string{val}, layout); err != nil {
+				return err
+			}
+		}
+		v.Set(res)
+		return nil
+	}
+	return setScalar(v, vals[0], layout)
+}
+
+// isScalar 实现了 TextUnmarshaler 的类型当成一个值处理
+func isScalar(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+func setScalar(v reflect.Value, s string, layout string) error {
+	switch v.Type() {
+	case timeType:
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	if tu, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return tu.UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("web: 不支持绑定的类型 %s", v.Type())
+		}
+		v.SetBytes([]byte(s))
+	default:
+		return fmt.Errorf("web: 不支持绑定的类型 %s", v.Type())
+	}
+	return nil
+}
+
+// bindPlan 结构体的绑定信息，按照类型缓存，避免每次请求都重新解析标签
+type bindPlan struct {
+	fields []*bindField
+	// hasForm 包括嵌套的结构体在内，有没有字段需要解析表单
+	hasForm bool
+}
+
+type bindField struct {
+	index int
+	// name 字段在结构体里面的路径
+	name       string
+	sources    []bindSource
+	def        string
+	hasDefault bool
+	layout     string
+	// file 字段是 *multipart.FileHeader 或者 []*multipart.FileHeader
+	file bool
+	// nested 没有标签的结构体字段
+	nested *bindPlan
+}
+
+func (f *bindField) hasSource(source string) bool {
+	for _, s := range f.sources {
+		if s.source == source {
+			return true
+		}
+	}
+	return false
+}
+
+type bindSource struct {
+	source string
+	key    string
+}
+
+var bindPlans sync.Map
+
+func bindPlanFor(t reflect.Type) *bindPlan {
+	if p, ok := bindPlans.Load(t); ok {
+		return p.(*bindPlan)
+	}
+	p, _ := bindPlans.LoadOrStore(t, newBindPlan(t, "", map[reflect.Type]bool{}))
+	return p.(*bindPlan)
+}
+
+// newBindPlan visiting 用来跳过递归引用自己的结构体
+func newBindPlan(t reflect.Type, prefix string, visiting map[reflect.Type]bool) *bindPlan {
+	visiting[t] = true
+	defer delete(visiting, t)
+	res := &bindPlan{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		// 没有导出的嵌入结构体，里面导出的字段仍然可以赋值
+		embedded := sf.Anonymous && sf.Type.Kind() == reflect.Struct
+		if !sf.IsExported() && !embedded {
+			continue
+		}
+		f := &bindField{index: i, name: prefix + sf.Name, layout: sf.Tag.Get("layout")}
+		f.def, f.hasDefault = sf.Tag.Lookup("default")
+		for _, source := range bindSources {
+			if !sf.IsExported() {
+				break
+			}
+			key, ok := sf.Tag.Lookup(source)
+			if !ok || key == "-" {
+				continue
+			}
+			if key == "" {
+				key = sf.Name
+			}
+			f.sources = append(f.sources, bindSource{source: source, key: key})
+			res.hasForm = res.hasForm || source == "form"
+		}
+		f.file = sf.Type == fileHeaderType || sf.Type == reflect.SliceOf(fileHeaderType)
+		if len(f.sources) > 0 || (f.hasDefault && sf.IsExported()) {
+			res.fields = append(res.fields, f)
+			continue
+		}
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct || ft == timeType || isScalar(ft) || visiting[ft] {
+			continue
+		}
+		// 嵌入结构体的字段和外层的字段一样处理，不加前缀
+		nestedPrefix := f.name + "."
+		if embedded {
+			nestedPrefix = prefix
+		}
+		f.nested = newBindPlan(ft, nestedPrefix, visiting)
+		if len(f.nested.fields) > 0 {
+			res.fields = append(res.fields, f)
+			res.hasForm = res.hasForm || f.nested.hasForm
+		}
+	}
+	return res
+}
@@ -0,0 +1,224 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindPage struct {
+	Page int `query:"page" default:"1"`
+	Size int `query:"size" default:"20"`
+}
+
+type bindFilter struct {
+	Tags  []string   `query:"tag"`
+	Since *time.Time `query:"since" layout:"2006-01-02"`
+}
+
+type bindUser struct {
+	ID      int64         `path:"id"`
+	OrderID uuid.UUID     `path:"oid"`
+	Name    string        `form:"name"`
+	Age     *int          `form:"age"`
+	Token   string        `header:"X-Token"`
+	Session string        `cookie:"sid"`
+	Timeout time.Duration `query:"timeout" default:"3s"`
+	Active  bool          `query:"active"`
+	Score   float64       `query:"score"`
+	IP      net.IP        `header:"X-Real-IP"`
+	Lang    string        `header:"Accept-Language" query:"lang" default:"zh"`
+	Ignored string        `query:"-"`
+	secret  string        `query:"secret"`
+	bindPage
+	Filter *bindFilter
+	Extra  struct {
+		Trace string `header:"X-Trace"`
+	}
+}
+
+func TestContext_Bind(t *testing.T) {
+	age := 18
+	since := time.Date(2023, 2, 28, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name       string
+		req        func() *http.Request
+		pathParams map[string]string
+
+		wantUser bindUser
+		// wantFields 绑定失败的字段
+		wantFields []string
+	}{
+		{
+			name: "all sources",
+			req: func() *http.Request {
+				form := url.Values{"name": {"tom"}, "age": {"18"}}
+				req := httptest.NewRequest(http.MethodPost,
+					"/user/1?page=2&tag=a&tag=b&since=2023-02-28&timeout=1m&active=true&score=9.5&lang=en&secret=x",
+					strings.NewReader(form.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				req.Header.Set("X-Token", "token")
+				req.Header.Set("X-Real-IP", "127.0.0.1")
+				req.Header.Set("X-Trace", "trace")
+				req.AddCookie(&http.Cookie{Name: "sid", Value: "session"})
+				return req
+			},
+			pathParams: map[string]string{"id": "1", "oid": "3f2504e0-4f89-11d3-9a0c-0305e82c3301"},
+			wantUser: func() bindUser {
+				u := bindUser{
+					ID:       1,
+					OrderID:  uuid.MustParse("3f2504e0-4f89-11d3-9a0c-0305e82c3301"),
+					Name:     "tom",
+					Age:      &age,
+					Token:    "token",
+					Session:  "session",
+					Timeout:  time.Minute,
+					Active:   true,
+					Score:    9.5,
+					IP:       net.ParseIP("127.0.0.1"),
+					Lang:     "en",
+					bindPage: bindPage{Page: 2, Size: 20},
+					Filter:   &bindFilter{Tags: []string{"a", "b"}, Since: &since},
+				}
+				u.Extra.Trace = "trace"
+				return u
+			}(),
+		},
+		{
+			name: "defaults",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/user?page=", nil)
+			},
+			wantUser: bindUser{
+				Timeout:  3 * time.Second,
+				Lang:     "zh",
+				bindPage: bindPage{Page: 1, Size: 20},
+			},
+		},
+		{
+			name: "header before default",
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/user?lang=en", nil)
+				req.Header.Set("Accept-Language", "fr")
+				return req
+			},
+			wantUser: bindUser{
+				Timeout:  3 * time.Second,
+				Lang:     "en",
+				bindPage: bindPage{Page: 1, Size: 20},
+			},
+		},
+		{
+			name: "field errors",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/user?page=abc&size=10&since=yesterday&timeout=soon", nil)
+			},
+			pathParams: map[string]string{"id": "x"},
+			wantFields: []string{"ID", "Timeout", "Page", "Filter.Since"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := &Context{Req: tc.req(), PathParams: tc.pathParams}
+			var u bindUser
+			err := ctx.Bind(&u)
+			if len(tc.wantFields) > 0 {
+				var errs BindErrors
+				require.True(t, errors.As(err, &errs))
+				fields := make([]string, len(errs))
+				for i, e := range errs {
+					fields[i] = e.Field
+				}
+				assert.Equal(t, tc.wantFields, fields)
+				// 合法的字段仍然会被绑定
+				assert.Equal(t, 10, u.Size)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantUser, u)
+		})
+	}
+}
+
+func TestContext_Bind_FieldError(t *testing.T) {
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/user?page=abc", nil)}
+	var p bindPage
+	err := ctx.Bind(&p)
+	var errs BindErrors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 1)
+	fe := errs[0]
+	assert.Equal(t, "Page", fe.Field)
+	assert.Equal(t, "query", fe.Source)
+	assert.Equal(t, "page", fe.Key)
+	assert.Equal(t, "abc", fe.Value)
+	assert.True(t, errors.Is(fe, strconv.ErrSyntax))
+	assert.Equal(t, `web: 字段 Page 绑定失败，query [page] 的值 "abc" 不合法: strconv.ParseInt: parsing "abc": invalid syntax`, fe.Error())
+}
+
+func TestContext_Bind_DefaultOnly(t *testing.T) {
+	type search struct {
+		Size  int      `default:"20"`
+		Sort  string   `json:"sort" default:"id"`
+		Langs []string `default:"zh,en"`
+		Bad   int      `default:"abc"`
+	}
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/search?Size=5", nil)}
+	// 已经有值的字段保留原来的值，例如先用 BindJson 填过的字段
+	dst := search{Sort: "name"}
+	err := ctx.Bind(&dst)
+	var errs BindErrors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 1)
+	assert.Equal(t, "Bad", errs[0].Field)
+	assert.Equal(t, "default", errs[0].Source)
+	assert.Equal(t, 20, dst.Size)
+	assert.Equal(t, "name", dst.Sort)
+	assert.Equal(t, []string{"zh", "en"}, dst.Langs)
+}
+
+func TestContext_Bind_Multipart(t *testing.T) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	require.NoError(t, w.WriteField("name", "tom"))
+	require.NoError(t, w.WriteField("ids", "1"))
+	require.NoError(t, w.WriteField("ids", "2"))
+	require.NoError(t, w.Close())
+	req := httptest.NewRequest(http.MethodPost, "/user", body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	var dst struct {
+		Name string `form:"name"`
+		IDs  []int  `form:"ids"`
+	}
+	ctx := &Context{Req: req}
+	require.NoError(t, ctx.Bind(&dst))
+	assert.Equal(t, "tom", dst.Name)
+	assert.Equal(t, []int{1, 2}, dst.IDs)
+}
+
+func TestContext_Bind_Invalid(t *testing.T) {
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/user?ch=1", nil)}
+	var p bindPage
+	assert.EqualError(t, ctx.Bind(p), "web: Bind 只支持非 nil 的结构体指针")
+	assert.EqualError(t, ctx.Bind((*bindPage)(nil)), "web: Bind 只支持非 nil 的结构体指针")
+
+	var dst struct {
+		Ch chan int `query:"ch"`
+	}
+	err := ctx.Bind(&dst)
+	var errs BindErrors
+	require.True(t, errors.As(err, &errs))
+	assert.EqualError(t, errs[0].Err, "web: 不支持绑定的类型 chan int")
+}
@@ -0,0 +1,130 @@
+package web
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Codec 把请求体解码到 dst，BindBody 根据请求的 Content-Type 选择 Codec
+type Codec interface {
+	Decode(req *http.Request, dst any) error
+}
+
+// CodecFunc 函数形式的 Codec
+type CodecFunc func(req *http.Request, dst any) error
+
+func (f CodecFunc) Decode(req *http.Request, dst any) error {
+	return f(req, dst)
+}
+
+// ServerWithCodec 注册或者替换 mediaType 对应的 Codec，例如 application/yaml
+func ServerWithCodec(mediaType string, codec Codec) HTTPServerOption {
+	return func(server *HTTPServer) {
+		codecs := make(map[string]Codec, len(server.codecs)+1)
+		for mt, c := range server.codecs {
+			codecs[mt] = c
+		}
+		codecs[strings.ToLower(mediaType)] = codec
+		server.codecs = codecs
+	}
+}
+
+// defaultCodecs 默认支持的请求体类型
+var defaultCodecs = map[string]Codec{
+	"application/json":                  CodecFunc(decodeJSON),
+	"application/xml":                   CodecFunc(decodeXML),
+	"text/xml":                          CodecFunc(decodeXML),
+	"application/x-www-form-urlencoded": CodecFunc(decodeForm),
+	"multipart/form-data":               CodecFunc(decodeForm),
+	"application/msgpack":               CodecFunc(decodeMsgpack),
+	"application/x-msgpack":             CodecFunc(decodeMsgpack),
+	"application/protobuf":              CodecFunc(decodeProtobuf),
+	"application/x-protobuf":            CodecFunc(decodeProtobuf),
+}
+
+func decodeJSON(req *http.Request, dst any) error {
+	return json.NewDecoder(req.Body).Decode(dst)
+}
+
+func decodeXML(req *http.Request, dst any) error {
+	return xml.NewDecoder(req.Body).Decode(dst)
+}
+
+// decodeForm 使用 form 标签绑定，见 Context.Bind
+func decodeForm(req *http.Request, dst any) error {
+	ctx := &Context{Req: req}
+	return ctx.bind(dst, "form")
+}
+
+func decodeMsgpack(req *http.Request, dst any) error {
+	return msgpack.NewDecoder(req.Body).Decode(dst)
+}
+
+func decodeProtobuf(req *http.Request, dst any) error {
+	msg, ok := dst.(proto.Message)
+	if !ok {
+		return fmt.Errorf("web: protobuf 只能解码到 proto.Message，实际是 %T", dst)
+	}
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+// UnsupportedMediaTypeError 没有请求体类型对应的 Codec，RespProblem 渲染成 415
+type UnsupportedMediaTypeError struct {
+	ContentType string
+}
+
+func (e *UnsupportedMediaTypeError) Error() string {
+	if e.ContentType == "" {
+		return "web: 缺少 Content-Type"
+	}
+	return fmt.Sprintf("web: 不支持的 Content-Type [%s]", e.ContentType)
+}
+
+// codecFor 先精确匹配，再按照 +json、+xml 这样的后缀匹配，例如 application/vnd.api+json
+func (c *Context) codecFor(mediaType string) (Codec, bool) {
+	codecs := c.codecs
+	if codecs == nil {
+		codecs = defaultCodecs
+	}
+	if codec, ok := codecs[mediaType]; ok {
+		return codec, true
+	}
+	if idx := strings.LastIndexByte(mediaType, '+'); idx >= 0 {
+		typ, _, _ := strings.Cut(mediaType, "/")
+		codec, ok := codecs[typ+"/"+mediaType[idx+1:]]
+		return codec, ok
+	}
+	return nil, false
+}
+
+// BindBody 根据请求的 Content-Type 选择 Codec 解码请求体，解码之后使用 HTTPServer 的 Validator 校验
+// 没有对应的 Codec 的时候返回 UnsupportedMediaTypeError
+func (c *Context) BindBody(dst any) error {
+	ct, _, err := mime.ParseMediaType(c.Req.Header.Get("Content-Type"))
+	if err != nil {
+		return &UnsupportedMediaTypeError{ContentType: c.Req.Header.Get("Content-Type")}
+	}
+	codec, ok := c.codecFor(ct)
+	if !ok {
+		return &UnsupportedMediaTypeError{ContentType: ct}
+	}
+	if c.Req.Body == nil || c.Req.Body == http.NoBody {
+		return errors.New("web: body 为 nil")
+	}
+	if err = codec.Decode(c.Req, dst); err != nil {
+		return err
+	}
+	return c.validate(dst)
+}
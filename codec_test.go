@@ -0,0 +1,243 @@
+package web
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type codecUser struct {
+	XMLName xml.Name `json:"-" xml:"user" msgpack:"-"`
+	Name    string   `json:"name" xml:"name" form:"name" msgpack:"name" validate:"required"`
+	Age     int      `json:"age" xml:"age" form:"age" msgpack:"age"`
+	// Page 表单 Codec 只绑定 form 标签，不会读取查询参数
+	Page int `query:"page"`
+}
+
+func TestContext_BindBody(t *testing.T) {
+	msgpackBody, err := msgpack.Marshal(map[string]any{"name": "tom", "age": 18})
+	require.NoError(t, err)
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+
+		wantUser codecUser
+		wantErr  string
+	}{
+		{
+			name:        "json",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name":"tom","age":18}`,
+			wantUser:    codecUser{Name: "tom", Age: 18},
+		},
+		{
+			name:        "json suffix",
+			contentType: "application/vnd.api+json",
+			body:        `{"name":"tom","age":18}`,
+			wantUser:    codecUser{Name: "tom", Age: 18},
+		},
+		{
+			name:        "xml",
+			contentType: "application/xml",
+			body:        `<user><name>tom</name><age>18</age></user>`,
+			wantUser:    codecUser{XMLName: xml.Name{Local: "user"}, Name: "tom", Age: 18},
+		},
+		{
+			name:        "text xml",
+			contentType: "text/xml",
+			body:        `<user><name>tom</name><age>18</age></user>`,
+			wantUser:    codecUser{XMLName: xml.Name{Local: "user"}, Name: "tom", Age: 18},
+		},
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        url.Values{"name": {"tom"}, "age": {"18"}}.Encode(),
+			wantUser:    codecUser{Name: "tom", Age: 18},
+		},
+		{
+			name:        "msgpack",
+			contentType: "application/msgpack",
+			body:        string(msgpackBody),
+			wantUser:    codecUser{Name: "tom", Age: 18},
+		},
+		{
+			name:        "unsupported",
+			contentType: "text/csv",
+			body:        "tom,18",
+			wantErr:     "web: 不支持的 Content-Type [text/csv]",
+		},
+		{
+			name:    "missing content type",
+			body:    `{"name":"tom"}`,
+			wantErr: "web: 缺少 Content-Type",
+		},
+		{
+			name:        "validation",
+			contentType: "application/json",
+			body:        `{"age":18}`,
+			wantErr:     "web: 字段 Name 校验失败，不能为空",
+		},
+		{
+			name:        "form field error",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "name=tom&age=abc",
+			wantErr:     `web: 字段 Age 绑定失败，form [age] 的值 "abc" 不合法: strconv.ParseInt: parsing "abc": invalid syntax`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/user?page=2", strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			ctx := &Context{Req: req, validator: NewTagValidator()}
+			var u codecUser
+			err := ctx.BindBody(&u)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantUser, u)
+		})
+	}
+}
+
+func TestContext_BindBody_Multipart(t *testing.T) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	require.NoError(t, w.WriteField("name", "tom"))
+	fw, err := w.CreateFormFile("avatar", "avatar.png")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("png"))
+	require.NoError(t, err)
+	fw, err = w.CreateFormFile("photos", "1.png")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("1"))
+	require.NoError(t, err)
+	fw, err = w.CreateFormFile("photos", "2.png")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("2"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	var dst struct {
+		Name   string                  `form:"name"`
+		Avatar *multipart.FileHeader   `form:"avatar"`
+		Photos []*multipart.FileHeader `form:"photos"`
+		Cover  *multipart.FileHeader   `form:"cover"`
+	}
+	ctx := &Context{Req: req}
+	require.NoError(t, ctx.BindBody(&dst))
+	assert.Equal(t, "tom", dst.Name)
+	require.NotNil(t, dst.Avatar)
+	assert.Equal(t, "avatar.png", dst.Avatar.Filename)
+	f, err := dst.Avatar.Open()
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "png", string(data))
+	require.Len(t, dst.Photos, 2)
+	assert.Equal(t, "2.png", dst.Photos[1].Filename)
+	assert.Nil(t, dst.Cover)
+}
+
+func TestContext_BindBody_Protobuf(t *testing.T) {
+	data, err := proto.Marshal(wrapperspb.String("tom"))
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/user", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	ctx := &Context{Req: req}
+	msg := &wrapperspb.StringValue{}
+	require.NoError(t, ctx.BindBody(msg))
+	assert.Equal(t, "tom", msg.GetValue())
+
+	req = httptest.NewRequest(http.MethodPost, "/user", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/protobuf")
+	ctx = &Context{Req: req}
+	var u codecUser
+	assert.EqualError(t, ctx.BindBody(&u), "web: protobuf 只能解码到 proto.Message，实际是 *web.codecUser")
+}
+
+func TestHTTPServer_BindBody(t *testing.T) {
+	csv := CodecFunc(func(req *http.Request, dst any) error {
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		u, ok := dst.(*codecUser)
+		if !ok {
+			return errors.New("unexpected type")
+		}
+		u.Name, _, _ = strings.Cut(string(data), ",")
+		return nil
+	})
+	h := NewHttpServer(ServerWithCodec("Text/CSV", csv))
+	h.Post("/user", func(ctx *Context) {
+		var u codecUser
+		if err := ctx.BindBody(&u); err != nil {
+			ctx.RespProblem(err)
+			return
+		}
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(u.Name)
+	})
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+
+		wantCode int
+		wantResp string
+	}{
+		{
+			name:        "custom codec",
+			contentType: "text/csv",
+			body:        "tom,18",
+			wantCode:    http.StatusOK,
+			wantResp:    "tom",
+		},
+		{
+			name:        "default codec",
+			contentType: "application/json",
+			body:        `{"name":"jerry"}`,
+			wantCode:    http.StatusOK,
+			wantResp:    "jerry",
+		},
+		{
+			name:        "unsupported",
+			contentType: "application/yaml",
+			body:        "name: tom",
+			wantCode:    http.StatusUnsupportedMediaType,
+			wantResp:    `{"type":"about:blank","title":"Unsupported Media Type","status":415,"detail":"web: 不支持的 Content-Type [application/yaml]"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+		})
+	}
+	// 注册自定义的 Codec 不会影响别的 HTTPServer
+	_, ok := defaultCodecs["text/csv"]
+	assert.False(t, ok)
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// paramConstraint 类型参数的约束，例如 :id<int>
+type paramConstraint struct {
+	name string
+	// pattern 不带 ^ 和 $ 的正则表达式
+	pattern string
+	reg     *regexp.Regexp
+	// validate 正则之外的额外校验，例如数字是否溢出、日期是否合法，可以为 nil
+	validate func(val string) bool
+}
+
+func (c *paramConstraint) match(val string) bool {
+	return c.reg.MatchString(val) && (c.validate == nil || c.validate(val))
+}
+
+var (
+	constraintsMutex sync.RWMutex
+	paramConstraints = map[string]*paramConstraint{}
+)
+
+func init() {
+	RegisterParamConstraint("int", `-?[0-9]+`, func(val string) bool {
+		_, err := strconv.ParseInt(val, 10, 64)
+		return err == nil
+	})
+	RegisterParamConstraint("float", `-?[0-9]+(\.[0-9]+)?`, func(val string) bool {
+		_, err := strconv.ParseFloat(val, 64)
+		return err == nil
+	})
+	RegisterParamConstraint("alpha", `[a-zA-Z]+`, nil)
+	RegisterParamConstraint("alnum", `[a-zA-Z0-9]+`, nil)
+	RegisterParamConstraint("uuid", `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`, nil)
+	RegisterParamConstraint("date", `[0-9]{4}-[0-9]{2}-[0-9]{2}`, func(val string) bool {
+		_, err := time.Parse(dateLayout, val)
+		return err == nil
+	})
+}
+
+// RegisterParamConstraint 注册参数类型，注册之后就可以在路由里面使用 :name<类型>
+// pattern 是不带 ^ 和 $ 的正则表达式，validate 用于正则表达不了的校验，可以为 nil
+// 需要在注册路由之前调用，同名的类型会被覆盖
+func RegisterParamConstraint(name string, pattern string, validate func(val string) bool) {
+	reg, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		panic(fmt.Errorf("web: 参数类型 [%s] 的正则表达式错误 %w", name, err))
+	}
+	constraintsMutex.Lock()
+	defer constraintsMutex.Unlock()
+	paramConstraints[name] = &paramConstraint{
+		name:     name,
+		pattern:  pattern,
+		reg:      reg,
+		validate: validate,
+	}
+}
+
+func findParamConstraint(name string) (*paramConstraint, bool) {
+	constraintsMutex.RLock()
+	defer constraintsMutex.RUnlock()
+	c, ok := paramConstraints[name]
+	return c, ok
+}
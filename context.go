@@ -3,23 +3,113 @@ package web
 import (
 	"encoding/json"
 	"errors"
+	"github.com/google/uuid"
 	"net/http"
 	"net/url"
 	"routing/template"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
+// Context 一次请求的上下文，HTTPServer 通过 contextPool 复用
+// 请求处理结束之后 Context 会被放回池子给下一个请求使用，所以 handler 和 middleware 不能在返回之后继续持有 Context，
+// 包括 PathParams 和 QueryValue 背后的 map；需要交给别的 goroutine 的时候使用 Copy
 type Context struct {
 	Req              *http.Request
 	Resp             http.ResponseWriter
 	PathParams       map[string]string
 	cacheQueryValues url.Values
-	MatchedRoute     string
-	RespData         []byte
-	RespStatusCode   int
-	tplEngine        template.TemplateEngine
+	// queryParsed cacheQueryValues 已经解析过当前请求的查询参数
+	queryParsed    bool
+	MatchedRoute   string
+	RespData       []byte
+	RespStatusCode int
+	tplEngine      template.TemplateEngine
+	// validator 和 validationStatus 来自 HTTPServer，见 ServerWithValidator
+	validator        Validator
+	validationStatus int
+	codecs           map[string]Codec
 }
 
+var contextPool = sync.Pool{
+	New: func() any {
+		return &Context{}
+	},
+}
+
+// reset 清空上一个请求留下的状态，PathParams 和 cacheQueryValues 的 map 留着给下一个请求使用
+func (c *Context) reset() {
+	pathParams, queryValues := c.PathParams, c.cacheQueryValues
+	for k := range pathParams {
+		delete(pathParams, k)
+	}
+	for k := range queryValues {
+		delete(queryValues, k)
+	}
+	*c = Context{PathParams: pathParams, cacheQueryValues: queryValues}
+}
+
+// Copy 返回一个不会被复用的副本，可以在请求结束之后继续使用，例如交给别的 goroutine
+// 副本的 Resp 不会写到客户端，写入的内容会被丢弃
+func (c *Context) Copy() *Context {
+	header := http.Header{}
+	if c.Resp != nil {
+		header = c.Resp.Header().Clone()
+	}
+	return c.clone(&discardWriter{header: header})
+}
+
+// clone 复制 Context 的状态，副本使用 resp 作为 ResponseWriter
+func (c *Context) clone(resp http.ResponseWriter) *Context {
+	res := &Context{
+		Req:              c.Req,
+		Resp:             resp,
+		queryParsed:      c.queryParsed,
+		MatchedRoute:     c.MatchedRoute,
+		RespStatusCode:   c.RespStatusCode,
+		tplEngine:        c.tplEngine,
+		validator:        c.validator,
+		validationStatus: c.validationStatus,
+		codecs:           c.codecs,
+	}
+	if len(c.PathParams) > 0 {
+		res.PathParams = make(map[string]string, len(c.PathParams))
+		for k, v := range c.PathParams {
+			res.PathParams[k] = v
+		}
+	}
+	if len(c.cacheQueryValues) > 0 {
+		res.cacheQueryValues = make(url.Values, len(c.cacheQueryValues))
+		for k, v := range c.cacheQueryValues {
+			res.cacheQueryValues[k] = append([]string(nil), v...)
+		}
+	}
+	if c.RespData != nil {
+		res.RespData = append([]byte(nil), c.RespData...)
+	}
+	return res
+}
+
+// discardWriter Copy 出来的 Context 使用的 ResponseWriter，请求已经结束，写什么都没有意义
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (w *discardWriter) WriteHeader(statusCode int) {}
+
+// dateLayout <date> 类型参数的格式
+const dateLayout = "2006-01-02"
+
 type stringValue struct {
 	val string
 	err error
@@ -30,7 +120,10 @@ func (c *Context) BindJson(val any) error {
 		return errors.New("web: body 为 nil")
 	}
 	decoder := json.NewDecoder(c.Req.Body)
-	return decoder.Decode(val)
+	if err := decoder.Decode(val); err != nil {
+		return err
+	}
+	return c.validate(val)
 }
 
 func (c *Context) FormValue(key string) stringValue {
@@ -58,6 +151,53 @@ func (s stringValue) AsInt64() (int64, error) {
 	return strconv.ParseInt(s.val, 10, 64)
 }
 
+func (s stringValue) AsInt() (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	return strconv.Atoi(s.val)
+}
+
+func (s stringValue) AsUint64() (uint64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	return strconv.ParseUint(s.val, 10, 64)
+}
+
+func (s stringValue) AsFloat64() (float64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	return strconv.ParseFloat(s.val, 64)
+}
+
+func (s stringValue) AsBool() (bool, error) {
+	if s.err != nil {
+		return false, s.err
+	}
+	return strconv.ParseBool(s.val)
+}
+
+func (s stringValue) AsUUID() (uuid.UUID, error) {
+	if s.err != nil {
+		return uuid.UUID{}, s.err
+	}
+	return uuid.Parse(s.val)
+}
+
+// AsDate 按照 2006-01-02 的格式解析，和 <date> 类型参数的格式一致
+func (s stringValue) AsDate() (time.Time, error) {
+	return s.AsTime(dateLayout)
+}
+
+func (s stringValue) AsTime(layout string) (time.Time, error) {
+	if s.err != nil {
+		return time.Time{}, s.err
+	}
+	return time.Parse(layout, s.val)
+}
+
 func (s stringValue) AsString() (string, error) {
 	if s.err != nil {
 		return "", s.err
@@ -66,10 +206,7 @@ func (s stringValue) AsString() (string, error) {
 }
 
 func (c *Context) QueryValue(key string) stringValue {
-	if c.cacheQueryValues == nil {
-		c.cacheQueryValues = c.Req.URL.Query()
-	}
-	val, ok := c.cacheQueryValues[key]
+	val, ok := c.queryValues()[key]
 	if !ok {
 		return stringValue{
 			err: errors.New("query param not found"),
@@ -80,6 +217,39 @@ func (c *Context) QueryValue(key string) stringValue {
 	}
 }
 
+// queryValues 第一次访问的时候解析查询参数
+func (c *Context) queryValues() url.Values {
+	if !c.queryParsed {
+		if c.cacheQueryValues == nil {
+			c.cacheQueryValues = make(url.Values)
+		}
+		parseQuery(c.cacheQueryValues, c.Req.URL.RawQuery)
+		c.queryParsed = true
+	}
+	return c.cacheQueryValues
+}
+
+// parseQuery 和 url.ParseQuery 一样解析查询参数，区别是写入已有的 map，并且跳过非法的参数而不是返回错误
+func parseQuery(m url.Values, query string) {
+	for query != "" {
+		var key string
+		key, query, _ = strings.Cut(query, "&")
+		if key == "" || strings.Contains(key, ";") {
+			continue
+		}
+		key, value, _ := strings.Cut(key, "=")
+		key, err := url.QueryUnescape(key)
+		if err != nil {
+			continue
+		}
+		value, err = url.QueryUnescape(value)
+		if err != nil {
+			continue
+		}
+		m[key] = append(m[key], value)
+	}
+}
+
 func (c *Context) PathValue(key string) stringValue {
 	val, ok := c.PathParams[key]
 	if !ok {
@@ -97,8 +267,7 @@ func (c *Context) RespJson(status int, val any) error {
 	if err != nil {
 		return err
 	}
-	c.RespStatusCode = status
-	c.RespData = data
+	c.resp(status, "application/json; charset=utf-8", data)
 	return nil
 }
 
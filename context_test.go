@@ -0,0 +1,152 @@
+package web
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestContext_PathValue(t *testing.T) {
+	ctx := &Context{
+		PathParams: map[string]string{
+			"id":    "123",
+			"price": "12.5",
+			"ok":    "true",
+			"oid":   "3f2504e0-4f89-11d3-9a0c-0305e82c3301",
+			"day":   "2023-02-28",
+		},
+	}
+	id, err := ctx.PathValue("id").AsInt()
+	require.NoError(t, err)
+	assert.Equal(t, 123, id)
+
+	uid, err := ctx.PathValue("id").AsUint64()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(123), uid)
+
+	price, err := ctx.PathValue("price").AsFloat64()
+	require.NoError(t, err)
+	assert.Equal(t, 12.5, price)
+
+	ok, err := ctx.PathValue("ok").AsBool()
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	oid, err := ctx.PathValue("oid").AsUUID()
+	require.NoError(t, err)
+	assert.Equal(t, uuid.MustParse("3f2504e0-4f89-11d3-9a0c-0305e82c3301"), oid)
+
+	day, err := ctx.PathValue("day").AsDate()
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 2, 28, 0, 0, 0, 0, time.UTC), day)
+
+	_, err = ctx.PathValue("missing").AsInt()
+	assert.Error(t, err)
+	_, err = ctx.PathValue("price").AsInt()
+	assert.Error(t, err)
+}
+
+func TestContext_QueryValue(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query string
+		key   string
+
+		wantVal string
+		wantErr bool
+	}{
+		{
+			name:    "first value",
+			query:   "a=1&a=2&b=3",
+			key:     "a",
+			wantVal: "1",
+		},
+		{
+			name:    "escaped",
+			query:   "name=%E4%BD%A0%E5%A5%BD+world",
+			key:     "name",
+			wantVal: "你好 world",
+		},
+		{
+			name:    "empty value",
+			query:   "a=&b",
+			key:     "b",
+			wantVal: "",
+		},
+		{
+			name:    "skip invalid",
+			query:   "a=%zz&b=1;c=2&d=4",
+			key:     "d",
+			wantVal: "4",
+		},
+		{
+			name:    "invalid",
+			query:   "a=%zz",
+			key:     "a",
+			wantErr: true,
+		},
+		{
+			name:    "missing",
+			query:   "a=1",
+			key:     "b",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/?"+tc.query, nil)}
+			val, err := ctx.QueryValue(tc.key).AsString()
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantVal, val)
+		})
+	}
+}
+
+func TestContext_Copy(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	recorder.Header().Set("X-Trace", "1")
+	ctx := &Context{
+		Req:            httptest.NewRequest(http.MethodGet, "/user/1?name=tom", nil),
+		Resp:           recorder,
+		PathParams:     map[string]string{"id": "1"},
+		MatchedRoute:   "/user/:id",
+		RespData:       []byte("hello"),
+		RespStatusCode: http.StatusOK,
+	}
+	_, err := ctx.QueryValue("name").AsString()
+	require.NoError(t, err)
+
+	cp := ctx.Copy()
+	// 原来的 Context 被回收复用之后，副本不受影响
+	ctx.reset()
+	assert.Nil(t, ctx.Req)
+	assert.Empty(t, ctx.PathParams)
+	assert.Nil(t, ctx.RespData)
+
+	id, err := cp.PathValue("id").AsString()
+	require.NoError(t, err)
+	assert.Equal(t, "1", id)
+	name, err := cp.QueryValue("name").AsString()
+	require.NoError(t, err)
+	assert.Equal(t, "tom", name)
+	assert.Equal(t, "/user/:id", cp.MatchedRoute)
+	assert.Equal(t, []byte("hello"), cp.RespData)
+	assert.Equal(t, "1", cp.Resp.Header().Get("X-Trace"))
+
+	// 副本的响应不会写到客户端
+	cp.Resp.Header().Set("X-Copy", "1")
+	cp.Resp.WriteHeader(http.StatusTeapot)
+	_, err = cp.Resp.Write([]byte("ignored"))
+	require.NoError(t, err)
+	assert.Empty(t, recorder.Header().Get("X-Copy"))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Empty(t, recorder.Body.String())
+}
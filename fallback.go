@@ -0,0 +1,125 @@
+package web
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ServerWithNotFoundHandler 找不到路由的时候使用 handler 生成响应，调用之前 RespStatusCode 已经设置为 404
+func ServerWithNotFoundHandler(handler HandleFunc) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.notFoundHandler = handler
+	}
+}
+
+// ServerWithMethodNotAllowedHandler 路径存在但是 method 不匹配的时候使用 handler 生成响应
+// 调用之前 RespStatusCode 已经设置为 405，Allow 头部也已经设置好
+func ServerWithMethodNotAllowedHandler(handler HandleFunc) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.methodNotAllowedHandler = handler
+	}
+}
+
+// fallback 分组自定义的 404 和 405 处理，只对分组前缀下面的路径生效
+type fallback struct {
+	prefix string
+	// pattern 前缀里面有参数或者通配符的时候，用只注册了这个前缀的路由树匹配，规则和路由完全一样
+	pattern          *router
+	notFound         HandleFunc
+	methodNotAllowed HandleFunc
+}
+
+func newFallback(prefix string) *fallback {
+	f := &fallback{prefix: prefix}
+	if !strings.ContainsAny(prefix, ":*") {
+		return f
+	}
+	pattern := NewRouter()
+	noop := func(ctx *Context) {}
+	pattern.AddRoute(http.MethodGet, prefix, noop)
+	// 末尾的通配符本来就匹配剩下所有的段
+	if last := prefix[strings.LastIndexByte(prefix, '/')+1:]; last[0] != '*' {
+		pattern.AddRoute(http.MethodGet, prefix+"/*", noop)
+	}
+	f.pattern = &pattern
+	return f
+}
+
+func (f *fallback) match(path string) bool {
+	if f.pattern != nil {
+		ps := getParams()
+		defer putParams(ps)
+		_, ok := f.pattern.find(http.MethodGet, path, ps)
+		return ok
+	}
+	if f.prefix == "/" || path == f.prefix {
+		return true
+	}
+	return strings.HasPrefix(path, f.prefix) && path[len(f.prefix)] == '/'
+}
+
+// NotFound 分组前缀下面找不到路由的时候使用 handler，覆盖 ServerWithNotFoundHandler
+// 前缀里面的参数和通配符按照路由的规则匹配，例如 /user/:id 分组对 /user/42/nope 生效
+// 嵌套的分组前缀更长，优先级更高
+func (g *RouteGroup) NotFound(handler HandleFunc) {
+	g.router.setFallback(g.prefix, func(f *fallback) {
+		f.notFound = handler
+	})
+}
+
+// MethodNotAllowed 分组前缀下面 method 不匹配的时候使用 handler，覆盖 ServerWithMethodNotAllowedHandler
+func (g *RouteGroup) MethodNotAllowed(handler HandleFunc) {
+	g.router.setFallback(g.prefix, func(f *fallback) {
+		f.methodNotAllowed = handler
+	})
+}
+
+func (r *router) setFallback(prefix string, fn func(f *fallback)) {
+	r.update(func(tx *routeTx) {
+		fallbacks := make([]*fallback, 0, len(tx.table.fallbacks)+1)
+		var cur *fallback
+		for _, f := range tx.table.fallbacks {
+			if f.prefix == prefix {
+				cp := *f
+				f = &cp
+				cur = f
+			}
+			fallbacks = append(fallbacks, f)
+		}
+		if cur == nil {
+			cur = newFallback(prefix)
+			fallbacks = append(fallbacks, cur)
+		}
+		fn(cur)
+		// 前缀越长越优先
+		sort.SliceStable(fallbacks, func(i, j int) bool {
+			return len(fallbacks[i].prefix) > len(fallbacks[j].prefix)
+		})
+		tx.table.fallbacks = fallbacks
+	})
+}
+
+// fallbackFor 找到 path 对应的分组里面最具体的、pick 不为 nil 的处理
+func (r *router) fallbackFor(path string, pick func(f *fallback) HandleFunc) HandleFunc {
+	for _, f := range r.load().fallbacks {
+		if hf := pick(f); hf != nil && f.match(path) {
+			return hf
+		}
+	}
+	return nil
+}
+
+func (h *HTTPServer) notFoundFor(r *router, path string) HandleFunc {
+	if hf := r.fallbackFor(path, func(f *fallback) HandleFunc { return f.notFound }); hf != nil {
+		return hf
+	}
+	return h.notFoundHandler
+}
+
+func (h *HTTPServer) methodNotAllowedFor(r *router, path string) HandleFunc {
+	if hf := r.fallbackFor(path, func(f *fallback) HandleFunc { return f.methodNotAllowed }); hf != nil {
+		return hf
+	}
+	return h.methodNotAllowedHandler
+}
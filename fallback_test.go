@@ -0,0 +1,176 @@
+package web
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPServer_Fallback(t *testing.T) {
+	var handler HandleFunc = func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+	}
+	var html = func(body string) HandleFunc {
+		return func(ctx *Context) {
+			ctx.Resp.Header().Set("Content-Type", "text/html; charset=utf-8")
+			ctx.RespData = []byte("<h1>" + body + "</h1>")
+		}
+	}
+	var json = func(body string) HandleFunc {
+		return func(ctx *Context) {
+			ctx.Resp.Header().Set("Content-Type", "application/json")
+			ctx.RespData = []byte(`{"error":"` + body + `"}`)
+		}
+	}
+	h := NewHttpServer(
+		ServerWithNotFoundHandler(html("not found")),
+		ServerWithMethodNotAllowedHandler(html("method not allowed")))
+	h.Get("/home", handler)
+	api := h.Group("/api")
+	api.NotFound(json("not found"))
+	api.MethodNotAllowed(json("method not allowed"))
+	api.Get("/user", handler)
+	api.When(Header("X-Beta", "1")).Get("/beta", handler)
+	api.When(Accept("application/json")).Get("/report", handler)
+	h.When(Query("preview", "1")).Get("/preview", handler)
+	v2 := api.Group("/v2")
+	v2.NotFound(json("v2 not found"))
+	h.Host("admin.example.com").NotFound(json("admin not found"))
+	user := h.Group("/user/:id(^[0-9]+$)")
+	user.NotFound(json("user not found"))
+	user.Get("/profile", handler)
+
+	testCases := []struct {
+		name     string
+		method   string
+		host     string
+		path     string
+		accept   string
+		wantCode int
+		wantType string
+		wantResp string
+	}{
+		{
+			name:     "site not found",
+			method:   http.MethodGet,
+			path:     "/about",
+			wantCode: http.StatusNotFound,
+			wantType: "text/html; charset=utf-8",
+			wantResp: "<h1>not found</h1>",
+		},
+		{
+			name:     "site method not allowed",
+			method:   http.MethodPost,
+			path:     "/home",
+			wantCode: http.StatusMethodNotAllowed,
+			wantType: "text/html; charset=utf-8",
+			wantResp: "<h1>method not allowed</h1>",
+		},
+		{
+			name:     "prefix without separator is not in group",
+			method:   http.MethodGet,
+			path:     "/apix",
+			wantCode: http.StatusNotFound,
+			wantType: "text/html; charset=utf-8",
+			wantResp: "<h1>not found</h1>",
+		},
+		{
+			name:     "api not found",
+			method:   http.MethodGet,
+			path:     "/api/order",
+			wantCode: http.StatusNotFound,
+			wantType: "application/json",
+			wantResp: `{"error":"not found"}`,
+		},
+		{
+			name:     "api method not allowed",
+			method:   http.MethodDelete,
+			path:     "/api/user",
+			wantCode: http.StatusMethodNotAllowed,
+			wantType: "application/json",
+			wantResp: `{"error":"method not allowed"}`,
+		},
+		{
+			name:     "predicate not matched",
+			method:   http.MethodGet,
+			path:     "/preview",
+			wantCode: http.StatusNotFound,
+			wantType: "text/html; charset=utf-8",
+			wantResp: "<h1>not found</h1>",
+		},
+		{
+			name:     "api predicate not matched",
+			method:   http.MethodGet,
+			path:     "/api/beta",
+			wantCode: http.StatusNotFound,
+			wantType: "application/json",
+			wantResp: `{"error":"not found"}`,
+		},
+		{
+			name:     "not acceptable keeps default response",
+			method:   http.MethodGet,
+			path:     "/api/report",
+			accept:   "text/html",
+			wantCode: http.StatusNotAcceptable,
+			wantResp: "NOT ACCEPTABLE",
+		},
+		{
+			name:     "nested group",
+			method:   http.MethodGet,
+			path:     "/api/v2/order",
+			wantCode: http.StatusNotFound,
+			wantType: "application/json",
+			wantResp: `{"error":"v2 not found"}`,
+		},
+		{
+			name:     "param prefix",
+			method:   http.MethodGet,
+			path:     "/user/42/nope",
+			wantCode: http.StatusNotFound,
+			wantType: "application/json",
+			wantResp: `{"error":"user not found"}`,
+		},
+		{
+			name:     "param prefix itself",
+			method:   http.MethodGet,
+			path:     "/user/42",
+			wantCode: http.StatusNotFound,
+			wantType: "application/json",
+			wantResp: `{"error":"user not found"}`,
+		},
+		{
+			name:     "param prefix not matched",
+			method:   http.MethodGet,
+			path:     "/user/tom/nope",
+			wantCode: http.StatusNotFound,
+			wantType: "text/html; charset=utf-8",
+			wantResp: "<h1>not found</h1>",
+		},
+		{
+			name:     "host",
+			method:   http.MethodGet,
+			host:     "admin.example.com",
+			path:     "/home",
+			wantCode: http.StatusNotFound,
+			wantType: "application/json",
+			wantResp: `{"error":"admin not found"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.host != "" {
+				req.Host = tc.host
+			}
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+		})
+	}
+}
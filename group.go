@@ -0,0 +1,167 @@
+package web
+
+import (
+	"net/http"
+	"sync"
+)
+
+// RouteGroup 路由分组，组内的路由共享同一个前缀和 middleware
+// 分组的 middleware 挂在前缀对应的节点上，所以每个请求只会执行一次
+type RouteGroup struct {
+	prefix string
+	mds    []Middleware
+	parent *RouteGroup
+	server *HTTPServer
+	// router 分组的路由注册到哪棵路由树上，虚拟主机的分组注册到对应主机的路由树上
+	router *router
+	// preds 分组内的路由都要满足的条件
+	preds []Predicate
+
+	mu sync.Mutex
+	// 已经挂载过 middleware 的 http method，路由树是按照 method 区分的
+	attached map[string]bool
+}
+
+// Group 创建路由分组，prefix 必须以 [/] 开头，并且不能以 [/] 结尾
+func (h *HTTPServer) Group(prefix string, mds ...Middleware) *RouteGroup {
+	return newRouteGroup(h, &h.router, nil, prefix, mds)
+}
+
+// When 创建带条件的分组，见 RouteGroup.When
+func (h *HTTPServer) When(preds ...Predicate) *RouteGroup {
+	return h.Group("/").When(preds...)
+}
+
+// Group 创建嵌套的路由分组，前缀和 middleware 都会叠加在当前分组之上
+func (g *RouteGroup) Group(prefix string, mds ...Middleware) *RouteGroup {
+	res := newRouteGroup(g.server, g.router, g, g.fullPath(prefix), mds)
+	res.preds = g.preds
+	return res
+}
+
+// When 返回前缀相同的分组，分组内注册的路由在路径匹配之后还需要满足 preds
+// 例如 h.When(Accept("application/json")).Get("/user", jsonHandler)
+func (g *RouteGroup) When(preds ...Predicate) *RouteGroup {
+	res := newRouteGroup(g.server, g.router, g, g.prefix, nil)
+	res.preds = append(g.preds[:len(g.preds):len(g.preds)], preds...)
+	return res
+}
+
+func newRouteGroup(server *HTTPServer, r *router, parent *RouteGroup, prefix string, mds []Middleware) *RouteGroup {
+	if prefix == "" || prefix[0] != '/' {
+		panic("web: 分组前缀必须以 [/] 开头")
+	}
+	if prefix != "/" && prefix[len(prefix)-1] == '/' {
+		panic("web: 分组前缀不能以 [/] 结尾")
+	}
+	return &RouteGroup{
+		prefix:   prefix,
+		mds:      mds,
+		parent:   parent,
+		server:   server,
+		router:   r,
+		attached: make(map[string]bool),
+	}
+}
+
+// Prefix 返回分组完整的前缀
+func (g *RouteGroup) Prefix() string {
+	return g.prefix
+}
+
+func (g *RouteGroup) AddRoute(method string, path string, handleFunc HandleFunc, mds ...Middleware) {
+	g.attach(method)
+	g.router.addRoute(method, g.fullPath(path), g.preds, handleFunc, mds...)
+}
+
+// AddNamedRoute 注册命名路由，名字是全局的，不会加上分组前缀
+func (g *RouteGroup) AddNamedRoute(name string, method string, path string, handleFunc HandleFunc, mds ...Middleware) {
+	g.attach(method)
+	g.router.addNamedRoute(name, method, g.fullPath(path), g.preds, handleFunc, mds...)
+}
+
+// ReplaceRoute 替换分组内的路由，见 router.ReplaceRoute
+func (g *RouteGroup) ReplaceRoute(method string, path string, handleFunc HandleFunc, mds ...Middleware) {
+	g.attach(method)
+	g.router.ReplaceRoute(method, g.fullPath(path), handleFunc, mds...)
+}
+
+// RemoveRoute 删除分组内的路由，见 router.RemoveRoute
+func (g *RouteGroup) RemoveRoute(method string, path string) bool {
+	return g.router.RemoveRoute(method, g.fullPath(path))
+}
+
+func (g *RouteGroup) Get(path string, handleFunc HandleFunc, mds ...Middleware) {
+	g.AddRoute(http.MethodGet, path, handleFunc, mds...)
+}
+
+func (g *RouteGroup) Head(path string, handleFunc HandleFunc, mds ...Middleware) {
+	g.AddRoute(http.MethodHead, path, handleFunc, mds...)
+}
+
+func (g *RouteGroup) Post(path string, handleFunc HandleFunc, mds ...Middleware) {
+	g.AddRoute(http.MethodPost, path, handleFunc, mds...)
+}
+
+func (g *RouteGroup) Put(path string, handleFunc HandleFunc, mds ...Middleware) {
+	g.AddRoute(http.MethodPut, path, handleFunc, mds...)
+}
+
+func (g *RouteGroup) Patch(path string, handleFunc HandleFunc, mds ...Middleware) {
+	g.AddRoute(http.MethodPatch, path, handleFunc, mds...)
+}
+
+func (g *RouteGroup) Delete(path string, handleFunc HandleFunc, mds ...Middleware) {
+	g.AddRoute(http.MethodDelete, path, handleFunc, mds...)
+}
+
+func (g *RouteGroup) Connect(path string, handleFunc HandleFunc, mds ...Middleware) {
+	g.AddRoute(http.MethodConnect, path, handleFunc, mds...)
+}
+
+func (g *RouteGroup) Options(path string, handleFunc HandleFunc, mds ...Middleware) {
+	g.AddRoute(http.MethodOptions, path, handleFunc, mds...)
+}
+
+func (g *RouteGroup) Trace(path string, handleFunc HandleFunc, mds ...Middleware) {
+	g.AddRoute(http.MethodTrace, path, handleFunc, mds...)
+}
+
+// Any 为所有的 http method 注册同一个路由
+func (g *RouteGroup) Any(path string, handleFunc HandleFunc, mds ...Middleware) {
+	g.Match(anyMethods, path, handleFunc, mds...)
+}
+
+// Match 为指定的几个 http method 注册同一个路由
+func (g *RouteGroup) Match(methods []string, path string, handleFunc HandleFunc, mds ...Middleware) {
+	for _, method := range methods {
+		g.AddRoute(method, path, handleFunc, mds...)
+	}
+}
+
+// attach 第一次在某个 method 下注册路由的时候，把分组的 middleware 挂到对应的路由树上
+func (g *RouteGroup) attach(method string) {
+	if g.parent != nil {
+		g.parent.attach(method)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.attached[method] || len(g.mds) == 0 {
+		return
+	}
+	g.router.addMiddleware(method, g.prefix, g.mds...)
+	g.attached[method] = true
+}
+
+func (g *RouteGroup) fullPath(path string) string {
+	if path == "" || path[0] != '/' {
+		panic("web: 路径必须以 [/] 开头")
+	}
+	if g.prefix == "/" {
+		return path
+	}
+	if path == "/" {
+		return g.prefix
+	}
+	return g.prefix + path
+}
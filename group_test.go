@@ -0,0 +1,107 @@
+package web
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouteGroup(t *testing.T) {
+	var mdsBuilder = func(i byte) Middleware {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				ctx.RespData = append(ctx.RespData, i)
+				next(ctx)
+			}
+		}
+	}
+	var handler HandleFunc = func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = append(ctx.RespData, []byte(ctx.MatchedRoute)...)
+	}
+	h := NewHttpServer()
+	api := h.Group("/api", mdsBuilder('a'))
+	api.Get("/", handler)
+	api.Get("/user", handler)
+	api.Post("/user", handler)
+	v1 := api.Group("/v1", mdsBuilder('1'))
+	v1.AddRoute(http.MethodGet, "/user/:id", handler, mdsBuilder('r'))
+	v1.Get("/order/*", handler)
+	h.Group("/").Get("/home", handler)
+	h.Get("/api2", handler)
+
+	testCases := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode int
+		wantResp string
+	}{
+		{
+			name:     "group root",
+			method:   http.MethodGet,
+			path:     "/api",
+			wantCode: http.StatusOK,
+			wantResp: "a/api",
+		},
+		{
+			name:     "group route",
+			method:   http.MethodGet,
+			path:     "/api/user",
+			wantCode: http.StatusOK,
+			wantResp: "a/api/user",
+		},
+		{
+			name:     "another method",
+			method:   http.MethodPost,
+			path:     "/api/user",
+			wantCode: http.StatusOK,
+			wantResp: "a/api/user",
+		},
+		{
+			name:     "nested group",
+			method:   http.MethodGet,
+			path:     "/api/v1/user/123",
+			wantCode: http.StatusOK,
+			wantResp: "a1r/api/v1/user/:id",
+		},
+		{
+			name:     "nested group star",
+			method:   http.MethodGet,
+			path:     "/api/v1/order/a/b",
+			wantCode: http.StatusOK,
+			wantResp: "a1/api/v1/order/*",
+		},
+		{
+			name:     "root group",
+			method:   http.MethodGet,
+			path:     "/home",
+			wantCode: http.StatusOK,
+			wantResp: "/home",
+		},
+		{
+			name:     "outside group",
+			method:   http.MethodGet,
+			path:     "/api2",
+			wantCode: http.StatusOK,
+			wantResp: "/api2",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+		})
+	}
+
+	assert.PanicsWithValue(t, "web: 分组前缀必须以 [/] 开头", func() {
+		h.Group("api")
+	})
+	assert.PanicsWithValue(t, "web: 分组前缀不能以 [/] 结尾", func() {
+		h.Group("/api/")
+	})
+}
@@ -0,0 +1,174 @@
+package web
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// virtualHost 虚拟主机，每个主机有自己独立的路由树
+type virtualHost struct {
+	pattern string
+	// labels 按照 . 拆分的 pattern，:name 开头的标签匹配任意一个非空的标签
+	labels []string
+	router
+}
+
+// Host 返回虚拟主机的路由分组，只有请求的 Host 匹配 pattern 的时候才会在这个分组的路由里面查找
+// pattern 可以是精确的主机名，例如 api.example.com
+// 也可以用 :name 匹配一级子域名，例如 :tenant.example.com，匹配到的值会放进 PathParams
+// 精确的主机名优先，通配的主机按照注册顺序匹配，都匹配不上的时候使用默认的路由
+// 同一个 pattern 多次调用 Host 共享同一棵路由树
+func (h *HTTPServer) Host(pattern string, mds ...Middleware) *RouteGroup {
+	vh := h.virtualHost(pattern)
+	return newRouteGroup(h, &vh.router, nil, "/", mds)
+}
+
+// hostTable 虚拟主机的快照，和路由表一样写时复制，发布之后就不会再修改，请求进来的时候不需要加锁
+type hostTable struct {
+	// exact 精确匹配的虚拟主机，wildcard 带有 :name 标签的虚拟主机，按照注册顺序排列
+	exact    map[string]*virtualHost
+	wildcard []*virtualHost
+}
+
+var emptyHosts = &hostTable{}
+
+func (h *HTTPServer) loadHosts() *hostTable {
+	if t, ok := h.hosts.Load().(*hostTable); ok {
+		return t
+	}
+	return emptyHosts
+}
+
+func (h *HTTPServer) virtualHost(pattern string) *virtualHost {
+	if pattern == "" {
+		panic("web: 虚拟主机不能为空字符串")
+	}
+	pattern = strings.ToLower(pattern)
+	h.hostsMu.Lock()
+	defer h.hostsMu.Unlock()
+	old := h.loadHosts()
+	if vh, ok := old.exact[pattern]; ok {
+		return vh
+	}
+	for _, vh := range old.wildcard {
+		if vh.pattern == pattern {
+			return vh
+		}
+	}
+	labels := strings.Split(pattern, ".")
+	wildcard := false
+	for _, label := range labels {
+		if label == "" || label == ":" {
+			panic(fmt.Sprintf("web: 非法的虚拟主机 [%s]", pattern))
+		}
+		if label[0] == ':' {
+			wildcard = true
+		}
+	}
+	vh := &virtualHost{
+		pattern: pattern,
+		labels:  labels,
+		router:  NewRouter(),
+	}
+	hosts := &hostTable{exact: old.exact, wildcard: old.wildcard}
+	if wildcard {
+		hosts.wildcard = append(old.wildcard[:len(old.wildcard):len(old.wildcard)], vh)
+	} else {
+		hosts.exact = make(map[string]*virtualHost, len(old.exact)+1)
+		for p, v := range old.exact {
+			hosts.exact[p] = v
+		}
+		hosts.exact[pattern] = vh
+	}
+	h.hosts.Store(hosts)
+	return vh
+}
+
+// hostRouter 根据请求的 Host 选择路由树，通配主机匹配到的值写入 ps
+func (h *HTTPServer) hostRouter(host string, ps *params) *router {
+	hosts := h.loadHosts()
+	if len(hosts.exact) == 0 && len(hosts.wildcard) == 0 {
+		return &h.router
+	}
+	host = strings.ToLower(stripPort(host))
+	if vh, ok := hosts.exact[host]; ok {
+		return &vh.router
+	}
+	for _, vh := range hosts.wildcard {
+		if vh.match(host, ps) {
+			return &vh.router
+		}
+	}
+	return &h.router
+}
+
+func (vh *virtualHost) match(host string, ps *params) bool {
+	if strings.Count(host, ".")+1 != len(vh.labels) {
+		return false
+	}
+	paramLen := len(*ps)
+	for _, label := range vh.labels {
+		cur := host
+		if idx := strings.IndexByte(host, '.'); idx >= 0 {
+			cur, host = host[:idx], host[idx+1:]
+		}
+		if label[0] == ':' && cur != "" {
+			*ps = append(*ps, param{key: label[1:], val: cur})
+			continue
+		}
+		if label != cur {
+			*ps = (*ps)[:paramLen]
+			return false
+		}
+	}
+	return true
+}
+
+func stripPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// URLFor 先在默认的路由里面找，找不到再按照注册顺序在虚拟主机里面找
+// 虚拟主机的路由只生成路径部分，不包括主机名
+func (h *HTTPServer) URLFor(name string, params ...any) (string, error) {
+	if _, ok := h.load().names[name]; ok {
+		return h.router.URLFor(name, params...)
+	}
+	for _, vh := range h.virtualHosts() {
+		if _, ok := vh.load().names[name]; ok {
+			return vh.router.URLFor(name, params...)
+		}
+	}
+	return h.router.URLFor(name, params...)
+}
+
+// Routes 返回默认路由和所有虚拟主机的路由，虚拟主机的路由会带上 Host
+func (h *HTTPServer) Routes() []RouteInfo {
+	res := h.router.Routes()
+	for _, vh := range h.virtualHosts() {
+		routes := vh.router.Routes()
+		for i := range routes {
+			routes[i].Host = vh.pattern
+		}
+		res = append(res, routes...)
+	}
+	return res
+}
+
+// virtualHosts 精确的主机按照名字排序，然后是按照注册顺序排列的通配主机
+func (h *HTTPServer) virtualHosts() []*virtualHost {
+	hosts := h.loadHosts()
+	res := make([]*virtualHost, 0, len(hosts.exact)+len(hosts.wildcard))
+	for _, vh := range hosts.exact {
+		res = append(res, vh)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].pattern < res[j].pattern
+	})
+	return append(res, hosts.wildcard...)
+}
@@ -0,0 +1,162 @@
+package web
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestHTTPServer_Host(t *testing.T) {
+	var handler = func(name string) HandleFunc {
+		return func(ctx *Context) {
+			ctx.RespStatusCode = http.StatusOK
+			tenant, _ := ctx.PathValue("tenant").AsString()
+			id, _ := ctx.PathValue("id").AsString()
+			ctx.RespData = []byte(name + ":" + tenant + ":" + id)
+		}
+	}
+	h := NewHttpServer()
+	h.Get("/user/:id", handler("default"))
+	h.Host("api.example.com").Get("/user/:id", handler("api"))
+	admin := h.Host("admin.example.com")
+	admin.Group("/v1").Get("/user/:id", handler("admin"))
+	h.Host(":tenant.example.com").Get("/user/:id", handler("tenant"))
+	h.Host(":tenant.example.com").Post("/user/:id", handler("tenant"))
+
+	testCases := []struct {
+		name     string
+		method   string
+		host     string
+		path     string
+		wantCode int
+		wantResp string
+	}{
+		{
+			name:     "exact host",
+			method:   http.MethodGet,
+			host:     "api.example.com",
+			path:     "/user/1",
+			wantCode: http.StatusOK,
+			wantResp: "api::1",
+		},
+		{
+			name:     "exact host with port and upper case",
+			method:   http.MethodGet,
+			host:     "API.example.com:8080",
+			path:     "/user/1",
+			wantCode: http.StatusOK,
+			wantResp: "api::1",
+		},
+		{
+			name:     "exact host not found",
+			method:   http.MethodGet,
+			host:     "admin.example.com",
+			path:     "/user/1",
+			wantCode: http.StatusNotFound,
+			wantResp: "NOT FOUND",
+		},
+		{
+			name:     "exact host group",
+			method:   http.MethodGet,
+			host:     "admin.example.com",
+			path:     "/v1/user/1",
+			wantCode: http.StatusOK,
+			wantResp: "admin::1",
+		},
+		{
+			name:     "wildcard host",
+			method:   http.MethodGet,
+			host:     "acme.example.com",
+			path:     "/user/2",
+			wantCode: http.StatusOK,
+			wantResp: "tenant:acme:2",
+		},
+		{
+			name:     "wildcard host method not allowed",
+			method:   http.MethodDelete,
+			host:     "acme.example.com",
+			path:     "/user/2",
+			wantCode: http.StatusMethodNotAllowed,
+			wantResp: "METHOD NOT ALLOWED",
+		},
+		{
+			name:     "nested subdomain falls back to default",
+			method:   http.MethodGet,
+			host:     "a.b.example.com",
+			path:     "/user/3",
+			wantCode: http.StatusOK,
+			wantResp: "default::3",
+		},
+		{
+			name:     "unknown host falls back to default",
+			method:   http.MethodGet,
+			host:     "localhost:8080",
+			path:     "/user/3",
+			wantCode: http.StatusOK,
+			wantResp: "default::3",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Host = tc.host
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+		})
+	}
+
+	routes := h.Routes()
+	require.Len(t, routes, 5)
+	assert.Equal(t, "", routes[0].Host)
+	assert.Equal(t, "admin.example.com", routes[1].Host)
+	assert.Equal(t, "/v1/user/:id", routes[1].Pattern)
+	assert.Equal(t, "api.example.com", routes[2].Host)
+	assert.Equal(t, ":tenant.example.com", routes[3].Host)
+
+	assert.PanicsWithValue(t, "web: 非法的虚拟主机 [api..com]", func() {
+		h.Host("api..com")
+	})
+}
+
+func TestHTTPServer_HostURLFor(t *testing.T) {
+	h := NewHttpServer()
+	h.Host("api.example.com").AddNamedRoute("user", http.MethodGet, "/user/:id", mockHandler)
+	res, err := h.URLFor("user", "id", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "/user/1", res)
+	_, err = h.URLFor("order")
+	assert.EqualError(t, err, "web: 找不到名字为 [order] 的路由")
+}
+
+// TestHTTPServer_HostConcurrent 运行期间注册虚拟主机，配合 go test -race 检查
+func TestHTTPServer_HostConcurrent(t *testing.T) {
+	h := NewHttpServer()
+	h.Get("/ping", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+				req.Host = "t" + strconv.Itoa(j%3) + ".example.com"
+				recorder := httptest.NewRecorder()
+				h.ServeHTTP(recorder, req)
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		h.Host("h"+strconv.Itoa(i)+".example.com").Get("/ping", mockHandler)
+		h.Host(":tenant"+strconv.Itoa(i)+".example"+strconv.Itoa(i)+".com").Get("/ping", mockHandler)
+	}
+	wg.Wait()
+	assert.Len(t, h.virtualHosts(), 100)
+}
@@ -0,0 +1,131 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Hook 生命周期钩子，例如启动前预热缓存，退出时刷新 tracing exporter、关闭数据库连接池
+type Hook func(ctx context.Context) error
+
+type lifecycleHook struct {
+	name    string
+	timeout time.Duration
+	hook    Hook
+}
+
+// ServerWithOnStart 注册启动钩子，按注册顺序在开始监听请求之前执行，任意一个失败都会终止启动
+// timeout <= 0 表示不单独设置超时
+func ServerWithOnStart(name string, timeout time.Duration, hook Hook) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.OnStart(name, timeout, hook)
+	}
+}
+
+// ServerWithOnShutdown 注册退出钩子，按注册顺序在 HTTP 服务停止之后执行
+func ServerWithOnShutdown(name string, timeout time.Duration, hook Hook) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.OnShutdown(name, timeout, hook)
+	}
+}
+
+// ServerWithShutdownTimeout 设置 Run 收到退出信号之后，等待优雅退出的最长时间
+func ServerWithShutdownTimeout(timeout time.Duration) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.shutdownTimeout = timeout
+	}
+}
+
+func (h *HTTPServer) OnStart(name string, timeout time.Duration, hook Hook) {
+	h.onStart = append(h.onStart, &lifecycleHook{name: name, timeout: timeout, hook: hook})
+}
+
+func (h *HTTPServer) OnShutdown(name string, timeout time.Duration, hook Hook) {
+	h.onShutdown = append(h.onShutdown, &lifecycleHook{name: name, timeout: timeout, hook: hook})
+}
+
+// Run 启动服务并监听退出信号，收到信号后执行优雅退出
+// 不传信号的时候默认监听 SIGINT 和 SIGTERM
+func (h *HTTPServer) Run(addr string, signals ...os.Signal) error {
+	return h.runUntilSignal(func() error {
+		return h.Start(addr)
+	}, signals)
+}
+
+func (h *HTTPServer) runUntilSignal(start func() error, signals []os.Signal) error {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, signals...)
+	defer signal.Stop(sigCh)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- start()
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case sig := <-sigCh:
+		h.log("web: 收到信号 %s，开始优雅退出\n", sig)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), h.shutdownTimeout)
+	defer cancel()
+	err := h.Shutdown(ctx)
+	if startErr := <-errCh; err == nil {
+		err = startErr
+	}
+	return err
+}
+
+// runHooks 按顺序执行钩子
+// failFast 为 true 的时候遇到错误立刻返回，否则执行完所有钩子，返回第一个错误
+func (h *HTTPServer) runHooks(ctx context.Context, hooks []*lifecycleHook, failFast bool) error {
+	var firstErr error
+	for _, hk := range hooks {
+		err := hk.run(ctx)
+		if err == nil {
+			continue
+		}
+		if failFast {
+			return err
+		}
+		h.log("%s\n", err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (l *lifecycleHook) run(ctx context.Context) (err error) {
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+	// 钩子未必会尊重 ctx，所以放到单独的 goroutine 里面，超时之后直接返回
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errCh <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		errCh <- l.hook(ctx)
+	}()
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("web: 执行钩子 [%s] 失败 %w", l.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPServer_Shutdown(t *testing.T) {
+	var seq []string
+	ready := make(chan struct{})
+	h := NewHttpServer(
+		ServerWithOnStart("first", 0, func(ctx context.Context) error {
+			seq = append(seq, "start-1")
+			return nil
+		}),
+		ServerWithOnStart("second", 0, func(ctx context.Context) error {
+			seq = append(seq, "start-2")
+			close(ready)
+			return nil
+		}),
+		ServerWithOnShutdown("flush", time.Second, func(ctx context.Context) error {
+			seq = append(seq, "shutdown-1")
+			return nil
+		}),
+		ServerWithOnShutdown("close", time.Second, func(ctx context.Context) error {
+			seq = append(seq, "shutdown-2")
+			return nil
+		}),
+	)
+	h.Get("/", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+	})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- h.Start("127.0.0.1:0")
+	}()
+	<-ready
+	require.NoError(t, h.Shutdown(context.Background()))
+	assert.NoError(t, <-errCh)
+	assert.Equal(t, []string{"start-1", "start-2", "shutdown-1", "shutdown-2"}, seq)
+	// 重复调用不会再次执行钩子
+	assert.NoError(t, h.Shutdown(context.Background()))
+	assert.Equal(t, 4, len(seq))
+}
+
+func TestHTTPServer_StartHookFailed(t *testing.T) {
+	hookErr := errors.New("mock error")
+	called := false
+	h := NewHttpServer(
+		ServerWithOnStart("fail", 0, func(ctx context.Context) error {
+			return hookErr
+		}),
+		ServerWithOnStart("never", 0, func(ctx context.Context) error {
+			called = true
+			return nil
+		}),
+	)
+	err := h.Start("127.0.0.1:0")
+	assert.ErrorIs(t, err, hookErr)
+	assert.False(t, called)
+}
+
+func TestHTTPServer_ShutdownHookTimeout(t *testing.T) {
+	called := false
+	h := NewHttpServer(
+		ServerWithOnShutdown("slow", 10*time.Millisecond, func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		}),
+		ServerWithOnShutdown("fast", 0, func(ctx context.Context) error {
+			called = true
+			return nil
+		}),
+	)
+	h.log = func(msg string, args ...any) {}
+	err := h.Shutdown(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	// 前面的钩子超时不影响后面的钩子
+	assert.True(t, called)
+}
+
+func TestHTTPServer_ShutdownBeforeStart(t *testing.T) {
+	var seq []string
+	h := NewHttpServer(
+		ServerWithOnStart("start", 0, func(ctx context.Context) error {
+			seq = append(seq, "start")
+			return nil
+		}),
+		ServerWithOnShutdown("shutdown", 0, func(ctx context.Context) error {
+			seq = append(seq, "shutdown")
+			return nil
+		}),
+	)
+	require.NoError(t, h.Shutdown(context.Background()))
+	assert.NoError(t, h.Start("127.0.0.1:0"))
+	// 已经退出的服务不会再执行启动钩子
+	assert.Equal(t, []string{"shutdown"}, seq)
+}
+
+func TestHTTPServer_ShutdownDuringStartHook(t *testing.T) {
+	var mu sync.Mutex
+	var seq []string
+	record := func(s string) {
+		mu.Lock()
+		defer mu.Unlock()
+		seq = append(seq, s)
+	}
+	entered, release := make(chan struct{}), make(chan struct{})
+	h := NewHttpServer(
+		ServerWithOnStart("slow", 0, func(ctx context.Context) error {
+			close(entered)
+			<-release
+			record("start")
+			return nil
+		}),
+		ServerWithOnShutdown("shutdown", 0, func(ctx context.Context) error {
+			record("shutdown")
+			return nil
+		}),
+	)
+	startCh := make(chan error, 1)
+	go func() {
+		startCh <- h.Start("127.0.0.1:0")
+	}()
+	<-entered
+	shutdownCh := make(chan error, 1)
+	go func() {
+		shutdownCh <- h.Shutdown(context.Background())
+	}()
+	// 启动钩子还没有结束，Shutdown 需要等待
+	select {
+	case <-shutdownCh:
+		t.Fatal("Shutdown 没有等待启动钩子结束")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	assert.NoError(t, <-shutdownCh)
+	assert.NoError(t, <-startCh)
+	assert.Equal(t, []string{"start", "shutdown"}, seq)
+}
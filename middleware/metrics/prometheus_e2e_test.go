@@ -21,10 +21,7 @@ func TestPrometheusBuilderE2E(t *testing.T) {
 			Name: "Tom",
 		})
 	})
-	go func() {
-		http.Handle("/metrics", promhttp.Handler())
-		http.ListenAndServe(":8085", nil)
-	}()
+	h.Mount("/metrics", promhttp.Handler())
 	h.Start(":8083")
 }
 
@@ -0,0 +1,227 @@
+package web
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Predicate 路由的附加条件，路径匹配之后才会判断
+// 同一个路径可以按照不同的条件注册多个 handler，例如按照 Accept 返回不同格式的数据
+type Predicate struct {
+	name string
+	// status 所有路由都不满足的时候返回的状态码
+	status int
+	match  func(req *http.Request) bool
+}
+
+func (p Predicate) String() string {
+	return p.name
+}
+
+// NewPredicate 自定义条件，所有路由都不满足的时候返回 status
+func NewPredicate(name string, status int, match func(req *http.Request) bool) Predicate {
+	return Predicate{name: name, status: status, match: match}
+}
+
+// Accept 客户端能够接受 mediaTypes 中的任意一个，支持 text/* 和 */* 形式的 Accept，没有 Accept 头部视为接受所有类型
+// 和 Negotiate 一样使用最具体的那一项的 q 值，例如 application/json;q=0, */* 不接受 application/json
+// 都不满足的时候返回 406
+func Accept(mediaTypes ...string) Predicate {
+	return Predicate{
+		name:   "Accept(" + strings.Join(mediaTypes, ",") + ")",
+		status: http.StatusNotAcceptable,
+		match: func(req *http.Request) bool {
+			header := req.Header.Get("Accept")
+			if header == "" {
+				return true
+			}
+			ranges := parseAccept(header)
+			for _, mt := range mediaTypes {
+				if acceptQuality(ranges, mt) > 0 {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// ContentType 请求体的类型是 mediaTypes 中的任意一个，忽略 charset 之类的参数
+// 都不满足的时候返回 415
+func ContentType(mediaTypes ...string) Predicate {
+	return Predicate{
+		name:   "ContentType(" + strings.Join(mediaTypes, ",") + ")",
+		status: http.StatusUnsupportedMediaType,
+		match: func(req *http.Request) bool {
+			ct, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+			if err != nil {
+				return false
+			}
+			for _, mt := range mediaTypes {
+				if strings.EqualFold(ct, mt) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// Header 请求头部 key 的值等于 value，value 为空字符串的时候只要求有这个头部
+// 都不满足的时候返回 404
+func Header(key string, value string) Predicate {
+	return Predicate{
+		name:   fmt.Sprintf("Header(%s=%s)", key, value),
+		status: http.StatusNotFound,
+		match: func(req *http.Request) bool {
+			vals := req.Header.Values(key)
+			if value == "" {
+				return len(vals) > 0
+			}
+			for _, val := range vals {
+				if val == value {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// Query 查询参数 key 的值等于 value，value 为空字符串的时候只要求有这个参数
+// 都不满足的时候返回 404
+func Query(key string, value string) Predicate {
+	return Predicate{
+		name:   fmt.Sprintf("Query(%s=%s)", key, value),
+		status: http.StatusNotFound,
+		match: func(req *http.Request) bool {
+			vals, ok := req.URL.Query()[key]
+			if value == "" {
+				return ok
+			}
+			for _, val := range vals {
+				if val == value {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// acceptRange Accept 头部里面的一项，例如 text/html;q=0.8
+type acceptRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// match 判断 mediaType 是否落在这一项的范围里面
+func (ar acceptRange) match(mediaType string) bool {
+	typ, subtype, ok := strings.Cut(strings.ToLower(mediaType), "/")
+	if !ok {
+		return false
+	}
+	if ar.typ != "*" && ar.typ != typ {
+		return false
+	}
+	return ar.subtype == "*" || ar.subtype == subtype
+}
+
+// acceptQuality mediaType 在 Accept 里面的 q 值，使用匹配的项里面最具体的那一项，没有匹配的项的时候是 0
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+	q, specificity := 0.0, -1
+	for _, ar := range ranges {
+		if !ar.match(mediaType) {
+			continue
+		}
+		s := 0
+		if ar.typ != "*" {
+			s++
+		}
+		if ar.subtype != "*" {
+			s++
+		}
+		if s > specificity {
+			q, specificity = ar.q, s
+		}
+	}
+	return q
+}
+
+// parseAccept 解析 Accept 头部，非法的项会被忽略，没有 q 的项默认是 1
+func parseAccept(header string) []acceptRange {
+	var res []acceptRange
+	for _, item := range strings.Split(header, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		mt, params, _ := strings.Cut(item, ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(mt)), "/")
+		if !ok || typ == "" || subtype == "" {
+			continue
+		}
+		ar := acceptRange{typ: typ, subtype: subtype, q: 1}
+		for _, p := range strings.Split(params, ";") {
+			key, val, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.EqualFold(key, "q") {
+				if q, err := strconv.ParseFloat(val, 64); err == nil {
+					ar.q = q
+				}
+			}
+		}
+		res = append(res, ar)
+	}
+	return res
+}
+
+// condRoute 带条件的路由，同一个节点上按照注册顺序判断
+type condRoute struct {
+	preds   []Predicate
+	handler HandleFunc
+	mds     []Middleware
+	// chain 命中这个路由时需要执行的全部路由 middleware，包括祖先节点上的
+	chain    []Middleware
+	compiled HandleFunc
+}
+
+func (c *condRoute) key() string {
+	names := make([]string, len(c.preds))
+	for i, p := range c.preds {
+		names[i] = p.name
+	}
+	return strings.Join(names, "&")
+}
+
+// matchRequest 返回第一个不满足的条件的状态码，全部满足的时候返回 0
+func (c *condRoute) matchRequest(req *http.Request) int {
+	for _, p := range c.preds {
+		if !p.match(req) {
+			return p.status
+		}
+	}
+	return 0
+}
+
+// selectHandler 按照注册顺序选择满足条件的路由，都不满足的时候使用没有条件的路由
+// 没有可用的路由时返回第一个带条件的路由里面第一个不满足的条件的状态码
+func (n *node) selectHandler(req *http.Request) (HandleFunc, int) {
+	status := 0
+	for _, c := range n.conds {
+		s := c.matchRequest(req)
+		if s == 0 {
+			return c.compiled, 0
+		}
+		if status == 0 {
+			status = s
+		}
+	}
+	if n.handler != nil {
+		return n.compiled, 0
+	}
+	return nil, status
+}
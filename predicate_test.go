@@ -0,0 +1,151 @@
+package web
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPServer_Predicates(t *testing.T) {
+	var handler = func(name string) HandleFunc {
+		return func(ctx *Context) {
+			ctx.RespStatusCode = http.StatusOK
+			ctx.RespData = []byte(name)
+		}
+	}
+	h := NewHttpServer()
+	h.When(Accept("application/json")).Get("/user", handler("json"))
+	h.When(Accept("text/html")).Get("/user", handler("html"))
+	api := h.Group("/api")
+	api.When(ContentType("application/json")).Post("/user", handler("create json"))
+	api.When(ContentType("application/x-www-form-urlencoded")).Post("/user", handler("create form"))
+	api.When(Header("X-Api-Version", "2")).Get("/order", handler("order v2"))
+	api.Get("/order", handler("order"))
+	api.When(Query("debug", "")).Get("/status", handler("debug"))
+
+	testCases := []struct {
+		name     string
+		method   string
+		path     string
+		header   map[string]string
+		wantCode int
+		wantResp string
+	}{
+		{
+			name:     "accept json",
+			method:   http.MethodGet,
+			path:     "/user",
+			header:   map[string]string{"Accept": "application/json"},
+			wantCode: http.StatusOK,
+			wantResp: "json",
+		},
+		{
+			name:     "accept html with q",
+			method:   http.MethodGet,
+			path:     "/user",
+			header:   map[string]string{"Accept": "application/json;q=0, text/*;q=0.8"},
+			wantCode: http.StatusOK,
+			wantResp: "html",
+		},
+		{
+			name:     "refuse json explicitly",
+			method:   http.MethodGet,
+			path:     "/user",
+			header:   map[string]string{"Accept": "application/json;q=0, */*"},
+			wantCode: http.StatusOK,
+			wantResp: "html",
+		},
+		{
+			name:     "no accept",
+			method:   http.MethodGet,
+			path:     "/user",
+			wantCode: http.StatusOK,
+			wantResp: "json",
+		},
+		{
+			name:     "not acceptable",
+			method:   http.MethodGet,
+			path:     "/user",
+			header:   map[string]string{"Accept": "application/xml"},
+			wantCode: http.StatusNotAcceptable,
+			wantResp: "NOT ACCEPTABLE",
+		},
+		{
+			name:     "content type with charset",
+			method:   http.MethodPost,
+			path:     "/api/user",
+			header:   map[string]string{"Content-Type": "application/json; charset=utf-8"},
+			wantCode: http.StatusOK,
+			wantResp: "create json",
+		},
+		{
+			name:     "content type form",
+			method:   http.MethodPost,
+			path:     "/api/user",
+			header:   map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			wantCode: http.StatusOK,
+			wantResp: "create form",
+		},
+		{
+			name:     "unsupported media type",
+			method:   http.MethodPost,
+			path:     "/api/user",
+			header:   map[string]string{"Content-Type": "text/plain"},
+			wantCode: http.StatusUnsupportedMediaType,
+			wantResp: "UNSUPPORTED MEDIA TYPE",
+		},
+		{
+			name:     "header version",
+			method:   http.MethodGet,
+			path:     "/api/order",
+			header:   map[string]string{"X-Api-Version": "2"},
+			wantCode: http.StatusOK,
+			wantResp: "order v2",
+		},
+		{
+			name:     "fallback to route without predicates",
+			method:   http.MethodGet,
+			path:     "/api/order",
+			header:   map[string]string{"X-Api-Version": "1"},
+			wantCode: http.StatusOK,
+			wantResp: "order",
+		},
+		{
+			name:     "query flag",
+			method:   http.MethodGet,
+			path:     "/api/status?debug",
+			wantCode: http.StatusOK,
+			wantResp: "debug",
+		},
+		{
+			name:     "query flag missing",
+			method:   http.MethodGet,
+			path:     "/api/status",
+			wantCode: http.StatusNotFound,
+			wantResp: "NOT FOUND",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader("{}"))
+			for key, val := range tc.header {
+				req.Header.Set(key, val)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+		})
+	}
+
+	routes := h.Routes()
+	assert.Equal(t, "/api/order", routes[0].Pattern)
+	assert.Nil(t, routes[0].Predicates)
+	assert.Equal(t, []string{"Header(X-Api-Version=2)"}, routes[1].Predicates)
+
+	assert.PanicsWithValue(t, "web: 路由冲突，重复注册[/user] Accept(application/json)", func() {
+		h.When(Accept("application/json")).Get("/user", handler("json"))
+	})
+}
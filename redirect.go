@@ -0,0 +1,99 @@
+package web
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ServerWithRedirectTrailingSlash 开启之后，/user/ 找不到路由但 /user 存在的时候，重定向到 /user
+func ServerWithRedirectTrailingSlash(enabled bool) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.redirectTrailingSlash = enabled
+	}
+}
+
+// ServerWithRedirectCleanPath 开启之后，路径里面有连续的 / 或者 . 和 .. 的时候，重定向到清理之后的路径
+// 例如 /user//1 和 /user/./1 都会重定向到 /user/1
+func ServerWithRedirectCleanPath(enabled bool) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.redirectCleanPath = enabled
+	}
+}
+
+// ServerWithRedirectCaseInsensitive 开启之后，忽略静态路径的大小写查找路由，找到了就重定向过去
+// 例如注册了 /user/:id，那么 /USER/Tom 会重定向到 /user/Tom
+func ServerWithRedirectCaseInsensitive(enabled bool) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.redirectCaseInsensitive = enabled
+	}
+}
+
+// redirectPath 在找不到路由的时候，按照开启的模式尝试修正路径
+func (h *HTTPServer) redirectPath(r *router, method string, reqPath string) (string, bool) {
+	if method == http.MethodConnect || reqPath == "/" {
+		return "", false
+	}
+	if !h.redirectTrailingSlash && !h.redirectCleanPath && !h.redirectCaseInsensitive {
+		return "", false
+	}
+	target := reqPath
+	if h.redirectCleanPath {
+		target = cleanPath(target)
+	}
+	if h.redirectTrailingSlash && len(target) > 1 {
+		target = strings.TrimRight(target, "/")
+		if target == "" {
+			target = "/"
+		}
+	}
+	// 开头连续的 / 只保留一个，否则 //evil.com 会被浏览器当成其它域名，变成开放重定向
+	target = "/" + strings.TrimLeft(target, "/")
+	if target != reqPath {
+		ps := getParams()
+		_, ok := h.findHandler(r, method, target, ps)
+		putParams(ps)
+		if ok {
+			return target, true
+		}
+	}
+	if h.redirectCaseInsensitive {
+		fixed, ok := r.findCaseInsensitivePath(method, target)
+		if !ok && method == http.MethodHead && h.autoHead {
+			fixed, ok = r.findCaseInsensitivePath(http.MethodGet, target)
+		}
+		if ok && fixed != reqPath {
+			return fixed, true
+		}
+	}
+	return "", false
+}
+
+// redirect GET 和 HEAD 使用 301，其余 method 使用 308，保证客户端重放请求的时候不会改变 method 和 body
+func (h *HTTPServer) redirect(context *Context, target string) {
+	u := *context.Req.URL
+	// Location 不能以 // 开头，见 redirectPath
+	u.Path = "/" + strings.TrimLeft(target, "/")
+	u.RawPath = ""
+	code := http.StatusPermanentRedirect
+	if context.Req.Method == http.MethodGet || context.Req.Method == http.MethodHead {
+		code = http.StatusMovedPermanently
+	}
+	context.Resp.Header().Set("Location", u.RequestURI())
+	context.RespStatusCode = code
+}
+
+// cleanPath 去掉连续的 /，处理 . 和 ..，但是保留结尾的 /，是否去掉结尾的 / 由 redirectTrailingSlash 决定
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	res := path.Clean(p)
+	if p[len(p)-1] == '/' && res != "/" {
+		res += "/"
+	}
+	return res
+}
@@ -0,0 +1,178 @@
+package web
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPServer_Redirect(t *testing.T) {
+	var handler HandleFunc = func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+	}
+	allModes := []HTTPServerOption{
+		ServerWithRedirectTrailingSlash(true),
+		ServerWithRedirectCleanPath(true),
+		ServerWithRedirectCaseInsensitive(true),
+	}
+	testCases := []struct {
+		name         string
+		opts         []HTTPServerOption
+		method       string
+		target       string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:     "disabled by default",
+			method:   http.MethodGet,
+			target:   "/user/",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "trailing slash",
+			opts:         []HTTPServerOption{ServerWithRedirectTrailingSlash(true)},
+			method:       http.MethodGet,
+			target:       "/user/?page=1",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/user?page=1",
+		},
+		{
+			name:         "trailing slash post",
+			opts:         []HTTPServerOption{ServerWithRedirectTrailingSlash(true)},
+			method:       http.MethodPost,
+			target:       "/user/",
+			wantCode:     http.StatusPermanentRedirect,
+			wantLocation: "/user",
+		},
+		{
+			name:         "duplicate slash",
+			opts:         []HTTPServerOption{ServerWithRedirectCleanPath(true)},
+			method:       http.MethodGet,
+			target:       "/user//123",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/user/123",
+		},
+		{
+			name:         "dot segments",
+			opts:         []HTTPServerOption{ServerWithRedirectCleanPath(true)},
+			method:       http.MethodGet,
+			target:       "/order/../user/./123",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/user/123",
+		},
+		{
+			name:     "clean path keeps trailing slash",
+			opts:     []HTTPServerOption{ServerWithRedirectCleanPath(true)},
+			method:   http.MethodGet,
+			target:   "/user//123/",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "case insensitive",
+			opts:         []HTTPServerOption{ServerWithRedirectCaseInsensitive(true)},
+			method:       http.MethodGet,
+			target:       "/USER/Tom",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/user/Tom",
+		},
+		{
+			name:         "case insensitive star",
+			opts:         []HTTPServerOption{ServerWithRedirectCaseInsensitive(true)},
+			method:       http.MethodGet,
+			target:       "/Static/CSS/a.css",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/static/CSS/a.css",
+		},
+		{
+			name:         "all modes",
+			opts:         allModes,
+			method:       http.MethodGet,
+			target:       "/USER//./Tom/",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/user/Tom",
+		},
+		{
+			name:     "no route",
+			opts:     allModes,
+			method:   http.MethodGet,
+			target:   "/order//1",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "found without redirect",
+			opts:     allModes,
+			method:   http.MethodGet,
+			target:   "/user/123",
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHttpServer(tc.opts...)
+			h.Get("/user", handler)
+			h.Post("/user", handler)
+			h.Get("/user/:id", handler)
+			h.Get("/static/*", handler)
+			req := httptest.NewRequest(tc.method, tc.target, nil)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantLocation, recorder.Header().Get("Location"))
+		})
+	}
+}
+
+func TestHTTPServer_Redirect_LeadingSlashes(t *testing.T) {
+	var handler HandleFunc = func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+	}
+	testCases := []struct {
+		name   string
+		opts   []HTTPServerOption
+		routes []string
+		target string
+
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			// //evil.com 会被浏览器当成其它域名
+			name:     "trailing slash",
+			opts:     []HTTPServerOption{ServerWithRedirectTrailingSlash(true)},
+			routes:   []string{"/*/:x"},
+			target:   "//evil.com/",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "trailing slash same host",
+			opts:         []HTTPServerOption{ServerWithRedirectTrailingSlash(true)},
+			routes:       []string{"/*/:x", "/evil.com"},
+			target:       "//evil.com/",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/evil.com",
+		},
+		{
+			name:         "case insensitive",
+			opts:         []HTTPServerOption{ServerWithRedirectCaseInsensitive(true)},
+			routes:       []string{"/evil.com"},
+			target:       "//EVIL.com",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/evil.com",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHttpServer(tc.opts...)
+			for _, route := range tc.routes {
+				h.Get(route, handler)
+			}
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantLocation, recorder.Header().Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package web
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+	"net/http"
+	"sort"
+)
+
+// ErrNotAcceptable Negotiate 找不到客户端能够接受的格式
+var ErrNotAcceptable = errors.New("web: 没有客户端能够接受的响应格式")
+
+// offer Negotiate 可以生成的一种响应格式
+type offer struct {
+	mediaType   string
+	contentType string
+	// encode 返回 false 表示 val 不能用这种格式输出，例如不是 proto.Message 的值不能输出成 protobuf
+	encode func(val any) ([]byte, bool, error)
+}
+
+// offers 按照服务端的偏好排列，客户端的 q 值相同的时候使用排在前面的格式
+var offers = []offer{
+	{mediaType: "application/json", contentType: "application/json; charset=utf-8", encode: encodeWith(json.Marshal)},
+	{mediaType: "application/xml", contentType: "application/xml; charset=utf-8", encode: encodeWith(xml.Marshal)},
+	{mediaType: "application/yaml", contentType: "application/yaml; charset=utf-8", encode: encodeWith(yaml.Marshal)},
+	{mediaType: "application/msgpack", contentType: "application/msgpack", encode: encodeWith(msgpack.Marshal)},
+	{mediaType: "application/x-protobuf", contentType: "application/x-protobuf", encode: encodeProtobuf},
+	{mediaType: "text/plain", contentType: "text/plain; charset=utf-8", encode: encodeText},
+	{mediaType: "text/xml", contentType: "text/xml; charset=utf-8", encode: encodeWith(xml.Marshal)},
+}
+
+func encodeWith(marshal func(val any) ([]byte, error)) func(val any) ([]byte, bool, error) {
+	return func(val any) ([]byte, bool, error) {
+		data, err := marshal(val)
+		return data, true, err
+	}
+}
+
+func encodeProtobuf(val any) ([]byte, bool, error) {
+	msg, ok := val.(proto.Message)
+	if !ok {
+		return nil, false, nil
+	}
+	data, err := proto.Marshal(msg)
+	return data, true, err
+}
+
+// encodeText 只有字符串、[]byte、fmt.Stringer 和 error 可以输出成纯文本
+func encodeText(val any) ([]byte, bool, error) {
+	switch v := val.(type) {
+	case string:
+		return []byte(v), true, nil
+	case []byte:
+		return v, true, nil
+	case fmt.Stringer:
+		return []byte(v.String()), true, nil
+	case error:
+		return []byte(v.Error()), true, nil
+	}
+	return nil, false, nil
+}
+
+// resp 设置 Content-Type、状态码和响应体
+func (c *Context) resp(status int, contentType string, data []byte) {
+	if c.Resp != nil && contentType != "" {
+		c.Resp.Header().Set("Content-Type", contentType)
+	}
+	c.RespStatusCode = status
+	c.RespData = data
+}
+
+func (c *Context) RespXML(status int, val any) error {
+	data, err := xml.Marshal(val)
+	if err != nil {
+		return err
+	}
+	c.resp(status, "application/xml; charset=utf-8", data)
+	return nil
+}
+
+func (c *Context) RespYAML(status int, val any) error {
+	data, err := yaml.Marshal(val)
+	if err != nil {
+		return err
+	}
+	c.resp(status, "application/yaml; charset=utf-8", data)
+	return nil
+}
+
+func (c *Context) RespProtobuf(status int, msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.resp(status, "application/x-protobuf", data)
+	return nil
+}
+
+func (c *Context) RespString(status int, s string) {
+	c.resp(status, "text/plain; charset=utf-8", []byte(s))
+}
+
+// RespBytes 原样输出 data，contentType 为空的时候不设置 Content-Type
+func (c *Context) RespBytes(status int, contentType string, data []byte) {
+	c.resp(status, contentType, data)
+}
+
+// Negotiate 根据 Accept 头部选择响应的格式，支持 JSON、XML、YAML、MessagePack、protobuf 和纯文本
+// 每种格式使用 Accept 里面最具体的那一项的 q 值，例如 text/html 比 text/* 优先，q 值相同的时候按照上面的顺序选择
+// 没有 Accept 头部的时候使用 JSON；protobuf 只用于 proto.Message，纯文本只用于字符串、fmt.Stringer 和 error
+// 某种格式编码失败的时候尝试下一种客户端能够接受的格式，全部失败的时候返回第一个编码错误
+// 没有客户端能够接受的格式时响应 406 并返回 ErrNotAcceptable
+func (c *Context) Negotiate(status int, val any) error {
+	if c.Resp != nil {
+		c.Resp.Header().Add("Vary", "Accept")
+	}
+	var encodeErr error
+	for _, o := range c.acceptableOffers() {
+		data, ok, err := o.encode(val)
+		if !ok {
+			continue
+		}
+		if err != nil {
+			if encodeErr == nil {
+				encodeErr = err
+			}
+			continue
+		}
+		c.resp(status, o.contentType, data)
+		return nil
+	}
+	if encodeErr != nil {
+		return encodeErr
+	}
+	c.RespStatusCode = http.StatusNotAcceptable
+	c.RespData = []byte("NOT ACCEPTABLE")
+	return ErrNotAcceptable
+}
+
+// acceptableOffers 客户端能够接受的格式，按照 q 值从高到低排列，q 值相同的保持 offers 里面的顺序
+func (c *Context) acceptableOffers() []*offer {
+	header := c.Req.Header.Get("Accept")
+	if header == "" {
+		res := make([]*offer, len(offers))
+		for i := range offers {
+			res[i] = &offers[i]
+		}
+		return res
+	}
+	ranges := parseAccept(header)
+	res := make([]*offer, 0, len(offers))
+	qs := make(map[*offer]float64, len(offers))
+	for i := range offers {
+		o := &offers[i]
+		if q := acceptQuality(ranges, o.mediaType); q > 0 {
+			res = append(res, o)
+			qs[o] = q
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return qs[res[i]] > qs[res[j]]
+	})
+	return res
+}
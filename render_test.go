@@ -0,0 +1,244 @@
+package web
+
+import (
+	"encoding/xml"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type renderUser struct {
+	XMLName xml.Name `json:"-" xml:"user" yaml:"-" msgpack:"-"`
+	Name    string   `json:"name" xml:"name" yaml:"name" msgpack:"name"`
+}
+
+func TestContext_Resp(t *testing.T) {
+	pb, err := proto.Marshal(wrapperspb.String("tom"))
+	require.NoError(t, err)
+	testCases := []struct {
+		name string
+		resp func(ctx *Context) error
+
+		wantCode        int
+		wantContentType string
+		wantResp        string
+	}{
+		{
+			name: "json",
+			resp: func(ctx *Context) error {
+				return ctx.RespJson(http.StatusOK, renderUser{Name: "tom"})
+			},
+			wantCode:        http.StatusOK,
+			wantContentType: "application/json; charset=utf-8",
+			wantResp:        `{"name":"tom"}`,
+		},
+		{
+			name: "xml",
+			resp: func(ctx *Context) error {
+				return ctx.RespXML(http.StatusCreated, renderUser{Name: "tom"})
+			},
+			wantCode:        http.StatusCreated,
+			wantContentType: "application/xml; charset=utf-8",
+			wantResp:        `<user><name>tom</name></user>`,
+		},
+		{
+			name: "yaml",
+			resp: func(ctx *Context) error {
+				return ctx.RespYAML(http.StatusOK, renderUser{Name: "tom"})
+			},
+			wantCode:        http.StatusOK,
+			wantContentType: "application/yaml; charset=utf-8",
+			wantResp:        "name: tom\n",
+		},
+		{
+			name: "protobuf",
+			resp: func(ctx *Context) error {
+				return ctx.RespProtobuf(http.StatusOK, wrapperspb.String("tom"))
+			},
+			wantCode:        http.StatusOK,
+			wantContentType: "application/x-protobuf",
+			wantResp:        string(pb),
+		},
+		{
+			name: "string",
+			resp: func(ctx *Context) error {
+				ctx.RespString(http.StatusNotFound, "not found")
+				return nil
+			},
+			wantCode:        http.StatusNotFound,
+			wantContentType: "text/plain; charset=utf-8",
+			wantResp:        "not found",
+		},
+		{
+			name: "bytes",
+			resp: func(ctx *Context) error {
+				ctx.RespBytes(http.StatusOK, "image/png", []byte("png"))
+				return nil
+			},
+			wantCode:        http.StatusOK,
+			wantContentType: "image/png",
+			wantResp:        "png",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHttpServer()
+			h.Get("/user", func(ctx *Context) {
+				require.NoError(t, tc.resp(ctx))
+			})
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user", nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantContentType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+		})
+	}
+}
+
+func TestContext_Negotiate(t *testing.T) {
+	user := renderUser{Name: "tom"}
+	mp, err := msgpack.Marshal(user)
+	require.NoError(t, err)
+	pb, err := proto.Marshal(wrapperspb.String("tom"))
+	require.NoError(t, err)
+	testCases := []struct {
+		name   string
+		accept string
+		val    any
+
+		wantCode        int
+		wantContentType string
+		wantResp        string
+		wantErr         error
+	}{
+		{
+			name:            "no accept",
+			val:             user,
+			wantCode:        http.StatusOK,
+			wantContentType: "application/json; charset=utf-8",
+			wantResp:        `{"name":"tom"}`,
+		},
+		{
+			name:            "any",
+			accept:          "*/*",
+			val:             user,
+			wantCode:        http.StatusOK,
+			wantContentType: "application/json; charset=utf-8",
+			wantResp:        `{"name":"tom"}`,
+		},
+		{
+			name:            "xml",
+			accept:          "application/xml",
+			val:             user,
+			wantCode:        http.StatusOK,
+			wantContentType: "application/xml; charset=utf-8",
+			wantResp:        `<user><name>tom</name></user>`,
+		},
+		{
+			name:            "q value",
+			accept:          "application/json;q=0.5, application/yaml",
+			val:             user,
+			wantCode:        http.StatusOK,
+			wantContentType: "application/yaml; charset=utf-8",
+			wantResp:        "name: tom\n",
+		},
+		{
+			name:            "specific range wins",
+			accept:          "application/*, application/json;q=0.1",
+			val:             user,
+			wantCode:        http.StatusOK,
+			wantContentType: "application/xml; charset=utf-8",
+			wantResp:        `<user><name>tom</name></user>`,
+		},
+		{
+			name:            "msgpack",
+			accept:          "text/html, application/msgpack;q=0.9",
+			val:             user,
+			wantCode:        http.StatusOK,
+			wantContentType: "application/msgpack",
+			wantResp:        string(mp),
+		},
+		{
+			name:            "protobuf",
+			accept:          "application/x-protobuf",
+			val:             wrapperspb.String("tom"),
+			wantCode:        http.StatusOK,
+			wantContentType: "application/x-protobuf",
+			wantResp:        string(pb),
+		},
+		{
+			name:     "protobuf needs proto message",
+			accept:   "application/x-protobuf",
+			val:      user,
+			wantCode: http.StatusNotAcceptable,
+			wantResp: "NOT ACCEPTABLE",
+			wantErr:  ErrNotAcceptable,
+		},
+		{
+			name:            "text",
+			accept:          "text/*",
+			val:             errors.New("tom"),
+			wantCode:        http.StatusOK,
+			wantContentType: "text/plain; charset=utf-8",
+			wantResp:        "tom",
+		},
+		{
+			name:            "text xml",
+			accept:          "text/xml, text/*;q=0.5",
+			val:             user,
+			wantCode:        http.StatusOK,
+			wantContentType: "text/xml; charset=utf-8",
+			wantResp:        `<user><name>tom</name></user>`,
+		},
+		{
+			name:            "fallback on encode error",
+			accept:          "application/xml, application/json;q=0.9",
+			val:             map[string]any{"name": "tom"},
+			wantCode:        http.StatusOK,
+			wantContentType: "application/json; charset=utf-8",
+			wantResp:        `{"name":"tom"}`,
+		},
+		{
+			name:     "refused",
+			accept:   "application/json;q=0, text/html",
+			val:      user,
+			wantCode: http.StatusNotAcceptable,
+			wantResp: "NOT ACCEPTABLE",
+			wantErr:  ErrNotAcceptable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHttpServer()
+			h.Get("/user", func(ctx *Context) {
+				err := ctx.Negotiate(http.StatusOK, tc.val)
+				assert.Equal(t, tc.wantErr, err)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/user", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantContentType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+			assert.Equal(t, "Accept", recorder.Header().Get("Vary"))
+		})
+	}
+}
+
+func TestContext_Negotiate_EncodeError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("Accept", "application/xml, application/yaml;q=0")
+	ctx := &Context{Req: req, Resp: httptest.NewRecorder()}
+	err := ctx.Negotiate(http.StatusOK, map[string]any{"name": "tom"})
+	assert.EqualError(t, err, "xml: unsupported type: map[string]interface {}")
+	assert.Equal(t, 0, ctx.RespStatusCode)
+}
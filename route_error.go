@@ -0,0 +1,396 @@
+package web
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// RouteErrorKind 路由错误的类型
+type RouteErrorKind int
+
+const (
+	// RouteInvalid 路由本身不合法，例如格式错误、正则表达式错误
+	RouteInvalid RouteErrorKind = iota + 1
+	// RouteConflict 和已经注册的路由冲突，注册的时候就会发现
+	RouteConflict
+	// RouteAmbiguous 可以注册，但是同一个请求可能命中多个路由，或者路由永远不会被命中，只有 Validate 才会返回
+	RouteAmbiguous
+)
+
+func (k RouteErrorKind) String() string {
+	switch k {
+	case RouteInvalid:
+		return "invalid"
+	case RouteConflict:
+		return "conflict"
+	case RouteAmbiguous:
+		return "ambiguous"
+	default:
+		return "unknown"
+	}
+}
+
+// RouteError 注册或者校验路由时发现的问题
+type RouteError struct {
+	Kind RouteErrorKind
+	// Host 虚拟主机，默认的路由为空
+	Host    string
+	Method  string
+	Pattern string
+	// Location 注册 Pattern 的代码位置，例如 /app/main.go:12
+	Location string
+	// Conflict 和 Pattern 冲突的已有路由，ConflictLocation 是注册它的代码位置
+	Conflict         string
+	ConflictLocation string
+	// Reason 问题的描述，和 AddRoute panic 的信息相同
+	Reason string
+	// Err 底层的错误，例如正则表达式的编译错误
+	Err error
+
+	// value AddRoute 原本 panic 的值
+	value any
+}
+
+func (e *RouteError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.Reason)
+	if e.Pattern != "" {
+		sb.WriteString(" [")
+		if e.Host != "" {
+			sb.WriteString(e.Host + " ")
+		}
+		sb.WriteString(e.Method + " " + e.Pattern + "]")
+	}
+	if e.Location != "" {
+		sb.WriteString(" 位于 " + e.Location)
+	}
+	if e.Conflict != "" {
+		sb.WriteString("，冲突的路由 " + e.Conflict)
+		if e.ConflictLocation != "" {
+			sb.WriteString(" 位于 " + e.ConflictLocation)
+		}
+	}
+	return sb.String()
+}
+
+func (e *RouteError) Unwrap() error {
+	return e.Err
+}
+
+// RouteErrors Validate 发现的全部问题
+type RouteErrors []*RouteError
+
+func (es RouteErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return fmt.Sprintf("web: 路由校验发现 %d 个问题\n%s", len(es), strings.Join(msgs, "\n"))
+}
+
+// conflictWith 和 n 下面已有的路由冲突
+func conflictWith(n *node, reason string) *RouteError {
+	res := &RouteError{Kind: RouteConflict, Reason: reason, value: reason}
+	if r := n.anyRoute(); r != nil {
+		res.Conflict, res.ConflictLocation = r.route, r.loc
+	}
+	return res
+}
+
+// toRouteError 把修改路由表时 panic 的值转换成 RouteError
+func toRouteError(v any) *RouteError {
+	if e, ok := v.(*RouteError); ok {
+		return e
+	}
+	res := &RouteError{Kind: RouteInvalid, Reason: fmt.Sprint(v), value: v}
+	if err, ok := v.(error); ok {
+		res.Err = err
+	}
+	return res
+}
+
+// anyRoute 返回 n 下面的第一个路由，用来在冲突的时候指出具体是哪个路由
+func (n *node) anyRoute() *node {
+	var res *node
+	n.walk(func(child *node) {
+		if res == nil {
+			res = child
+		}
+	})
+	return res
+}
+
+// TryAddRoute 和 AddRoute 一样，只是出错的时候返回 *RouteError 而不是 panic，路由表保持不变
+// 适合从配置文件注册路由，一次性报告所有的问题
+func (r *router) TryAddRoute(method string, path string, handleFunc HandleFunc, mds ...Middleware) error {
+	return r.tryAddRoute(method, path, nil, handleFunc, mds...)
+}
+
+func (r *router) tryAddRoute(method string, path string, preds []Predicate, handleFunc HandleFunc, mds ...Middleware) error {
+	err := r.tryUpdate(func(tx *routeTx) {
+		tx.addRoute(method, path, preds, handleFunc, mds...)
+	})
+	if err != nil {
+		err.Method, err.Pattern, err.Location = method, path, callerLocation()
+		return err
+	}
+	return nil
+}
+
+// TryAddRoute 在分组内注册路由，见 router.TryAddRoute
+func (g *RouteGroup) TryAddRoute(method string, path string, handleFunc HandleFunc, mds ...Middleware) error {
+	if path == "" || path[0] != '/' {
+		reason := "web: 路径必须以 [/] 开头"
+		return &RouteError{Kind: RouteInvalid, Method: method, Pattern: path, Location: callerLocation(), Reason: reason, value: reason}
+	}
+	g.attach(method)
+	return g.router.tryAddRoute(method, g.fullPath(path), g.preds, handleFunc, mds...)
+}
+
+// Validate 检查已经注册的路由，返回所有可能产生歧义的地方，没有问题的时候返回 nil
+// 目前会检查：
+//  1. 同一个路由里面重复的参数名，后面的值会覆盖前面的值
+//  2. 同一个位置上可能匹配同一段的多个混合参数路由，例如 :name.txt 和 file.:ext，先注册的优先
+//  3. 被混合参数路由遮蔽的参数、正则和通配符路由，例如 /files/:name.:ext 会抢走 /files/:id 所有带 . 的路径
+func (r *router) Validate() error {
+	if errs := r.validate(); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (r *router) validate() RouteErrors {
+	var res RouteErrors
+	t := r.load()
+	methods := make([]string, 0, len(t.trees))
+	for method := range t.trees {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		root := t.trees[method]
+		root.walk(func(n *node) {
+			if name, ok := duplicateParam(root, n.route); ok {
+				reason := fmt.Sprintf("web: 路由歧义，参数名 %s 重复出现", name)
+				res = append(res, &RouteError{Kind: RouteAmbiguous, Method: method, Pattern: n.route,
+					Location: n.loc, Reason: reason, value: reason})
+			}
+		})
+		root.visit(func(n *node) {
+			for i, a := range n.mixChildren {
+				for _, b := range n.mixChildren[i+1:] {
+					if !a.mixed.overlaps(b.mixed) {
+						continue
+					}
+					ra, rb := a.anyRoute(), b.anyRoute()
+					if ra == nil || rb == nil {
+						continue
+					}
+					reason := fmt.Sprintf("web: 路由歧义，%s 和 %s 可能匹配同一段，先注册的 %s 优先", a.path, b.path, a.path)
+					res = append(res, &RouteError{Kind: RouteAmbiguous, Method: method, Pattern: rb.route,
+						Location: rb.loc, Conflict: ra.route, ConflictLocation: ra.loc, Reason: reason, value: reason})
+				}
+			}
+			for _, a := range n.mixChildren {
+				for _, d := range []*node{n.reChild, n.pathChild, n.starChild} {
+					if d == nil {
+						continue
+					}
+					ra, rd, ok := shadowed(a, d)
+					if !ok {
+						continue
+					}
+					seg := d.path
+					if d.nodeType != STARPATH {
+						seg = ":" + seg
+					}
+					reason := fmt.Sprintf("web: 路由遮蔽，%s 比 %s 优先，能匹配 %s 的路径不会再匹配 %s", a.path, seg, ra.route, rd.route)
+					res = append(res, &RouteError{Kind: RouteAmbiguous, Method: method, Pattern: rd.route,
+						Location: rd.loc, Conflict: ra.route, ConflictLocation: ra.loc, Reason: reason, value: reason})
+				}
+			}
+		})
+	}
+	return res
+}
+
+// Validate 检查默认的路由和所有虚拟主机的路由，另外检查永远不会被匹配到的通配主机
+func (h *HTTPServer) Validate() error {
+	res := h.router.validate()
+	for _, vh := range h.virtualHosts() {
+		for _, e := range vh.router.validate() {
+			e.Host = vh.pattern
+			res = append(res, e)
+		}
+	}
+	wildcard := h.loadHosts().wildcard
+	for i, vh := range wildcard {
+		for _, prev := range wildcard[:i] {
+			if prev.covers(vh) {
+				reason := fmt.Sprintf("web: 虚拟主机歧义，%s 会被先注册的 %s 覆盖，永远不会被匹配到", vh.pattern, prev.pattern)
+				res = append(res, &RouteError{Kind: RouteAmbiguous, Host: vh.pattern, Reason: reason, value: reason})
+				break
+			}
+		}
+	}
+	if len(res) > 0 {
+		return res
+	}
+	return nil
+}
+
+// covers 能匹配 other 的主机都能匹配 vh
+func (vh *virtualHost) covers(other *virtualHost) bool {
+	if len(vh.labels) != len(other.labels) {
+		return false
+	}
+	for i, label := range vh.labels {
+		if label[0] != ':' && label != other.labels[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// visit 深度优先遍历所有节点，包括没有 handler 的节点
+func (n *node) visit(fn func(n *node)) {
+	fn(n)
+	for _, child := range n.children {
+		child.visit(fn)
+	}
+	for _, child := range n.mixChildren {
+		child.visit(fn)
+	}
+	for _, child := range []*node{n.reChild, n.pathChild, n.starChild} {
+		if child != nil {
+			child.visit(fn)
+		}
+	}
+}
+
+// duplicateParam 返回路由里面第一个重复的参数名，root 只用来解析参数
+func duplicateParam(root *node, pattern string) (string, bool) {
+	if pattern == "/" {
+		return "", false
+	}
+	seen := make(map[string]bool)
+	check := func(name string) bool {
+		if seen[name] {
+			return true
+		}
+		seen[name] = true
+		return false
+	}
+	for _, s := range strings.Split(pattern[1:], "/") {
+		if parts, ok := parseMixedSegment(s); ok {
+			for _, p := range parts {
+				if p.param != "" && check(p.param) {
+					return p.param, true
+				}
+			}
+			continue
+		}
+		var name string
+		switch s[0] {
+		case ':':
+			name, _, _ = root.parseParam(s)
+		case '*':
+			name = s[1:]
+		}
+		if name != "" && check(name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// shadowed 返回混合节点 mixed 下面遮蔽了同一个位置上的 other 下面的路由的第一对路由
+// 混合段先于参数、正则和通配符匹配，只要 mixed 下面有和 other 下面剩下的段一样的路由，能匹配这个路由的路径就不会再交给 other
+// 末尾的命名通配符匹配剩下所有的段，mixed 下面的任意一个路由都会遮蔽它的一部分路径
+// 正则参数不比较表达式，所以可能会误报
+func shadowed(mixed *node, other *node) (*node, *node, bool) {
+	var ra, rd *node
+	mixed.walk(func(a *node) {
+		other.walk(func(d *node) {
+			if ra == nil && ((other.nodeType == STARPATH && other.starParam() != "") || sameRest(a.route, d.route)) {
+				ra, rd = a, d
+			}
+		})
+	})
+	return ra, rd, ra != nil
+}
+
+// sameRest 两个路由在第一个不同的段之后剩下的段是否能匹配同样的路径
+func sameRest(a string, b string) bool {
+	sa, sb := strings.Split(a, "/"), strings.Split(b, "/")
+	if len(sa) != len(sb) {
+		return false
+	}
+	k := 0
+	for k < len(sa) && sa[k] == sb[k] {
+		k++
+	}
+	for i := k + 1; i < len(sa); i++ {
+		if sa[i] != sb[i] && !(isPlainParam(sa[i]) && isPlainParam(sb[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPlainParam 段是没有正则和类型的普通参数，例如 :id
+func isPlainParam(seg string) bool {
+	if seg == "" || seg[0] != ':' {
+		return false
+	}
+	if _, ok := parseMixedSegment(seg); ok {
+		return false
+	}
+	return !strings.ContainsAny(seg, "(<")
+}
+
+// overlaps 两个混合段是否可能匹配同一段
+// 只比较开头和结尾的静态部分，不考虑参数类型，所以可能会误报
+func (m *mixedSeg) overlaps(other *mixedSeg) bool {
+	p1, s1 := m.affixes()
+	p2, s2 := other.affixes()
+	if !strings.HasPrefix(p1, p2) && !strings.HasPrefix(p2, p1) {
+		return false
+	}
+	return strings.HasSuffix(s1, s2) || strings.HasSuffix(s2, s1)
+}
+
+// affixes 返回开头和结尾的静态部分
+func (m *mixedSeg) affixes() (prefix string, suffix string) {
+	if first := m.parts[0]; first.param == "" {
+		prefix = first.literal
+	}
+	if last := m.parts[len(m.parts)-1]; last.param == "" {
+		suffix = last.literal
+	}
+	return prefix, suffix
+}
+
+// pkgPrefix 当前包里面的函数名前缀，例如 routing.
+var pkgPrefix = func() string {
+	name := runtime.FuncForPC(reflect.ValueOf(NewRouter).Pointer()).Name()
+	return name[:strings.LastIndexByte(name, '.')+1]
+}()
+
+// callerLocation 返回调用栈上第一个不在当前包里面的位置，也就是用户注册路由的地方
+func callerLocation() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, pkgPrefix) || strings.HasSuffix(f.File, "_test.go") {
+			return fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package web
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"regexp/syntax"
+	"testing"
+)
+
+func TestRouter_TryAddRoute(t *testing.T) {
+	mockHandler := func(ctx *Context) {}
+	testCases := []struct {
+		name   string
+		exists []string
+		method string
+		path   string
+		hf     HandleFunc
+
+		wantKind     RouteErrorKind
+		wantReason   string
+		wantConflict string
+	}{
+		{
+			name:   "ok",
+			exists: []string{"/user/:id"},
+			method: http.MethodGet,
+			path:   "/user/:id/detail",
+			hf:     mockHandler,
+		},
+		{
+			name:       "invalid path",
+			method:     http.MethodGet,
+			path:       "user",
+			hf:         mockHandler,
+			wantKind:   RouteInvalid,
+			wantReason: "web: 路径必须以 [/] 开头",
+		},
+		{
+			name:       "nil handler",
+			method:     http.MethodGet,
+			path:       "/user",
+			wantKind:   RouteInvalid,
+			wantReason: "web: handler 不能为 nil",
+		},
+		{
+			name:         "duplicate",
+			exists:       []string{"/user/:id"},
+			method:       http.MethodGet,
+			path:         "/user/:id",
+			hf:           mockHandler,
+			wantKind:     RouteConflict,
+			wantReason:   "web: 路由冲突，重复注册[/user/:id]",
+			wantConflict: "/user/:id",
+		},
+		{
+			name:         "param name",
+			exists:       []string{"/user/:id/detail"},
+			method:       http.MethodGet,
+			path:         "/user/:uid",
+			hf:           mockHandler,
+			wantKind:     RouteConflict,
+			wantReason:   "web: 路由冲突，参数路由冲突，已有 id，新注册 uid",
+			wantConflict: "/user/:id/detail",
+		},
+		{
+			name:         "regex siblings",
+			exists:       []string{"/order/:id(^[0-9]+$)"},
+			method:       http.MethodGet,
+			path:         "/order/:id(^[a-z]+$)",
+			hf:           mockHandler,
+			wantKind:     RouteConflict,
+			wantReason:   "web: 路由冲突，正则路由冲突，已有 id，新注册 id",
+			wantConflict: "/order/:id(^[0-9]+$)",
+		},
+		{
+			name:         "star and param",
+			exists:       []string{"/static/*filepath"},
+			method:       http.MethodGet,
+			path:         "/static/:name",
+			hf:           mockHandler,
+			wantKind:     RouteConflict,
+			wantReason:   "web: 非法路由，已有通配符路由。不允许同时注册通配符路由和参数路由 [name]",
+			wantConflict: "/static/*filepath",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewRouter()
+			for _, p := range tc.exists {
+				r.AddRoute(http.MethodGet, p, mockHandler)
+			}
+			before := r.load()
+			err := r.TryAddRoute(tc.method, tc.path, tc.hf)
+			if tc.wantKind == 0 {
+				require.NoError(t, err)
+				_, ok := r.FindRoute(tc.method, "/user/1/detail")
+				assert.True(t, ok)
+				return
+			}
+			var re *RouteError
+			require.True(t, errors.As(err, &re))
+			assert.Equal(t, tc.wantKind, re.Kind)
+			assert.Equal(t, tc.wantReason, re.Reason)
+			assert.Equal(t, tc.method, re.Method)
+			assert.Equal(t, tc.path, re.Pattern)
+			assert.Contains(t, re.Location, "route_error_test.go")
+			assert.Equal(t, tc.wantConflict, re.Conflict)
+			if tc.wantConflict != "" {
+				assert.Contains(t, re.ConflictLocation, "route_error_test.go")
+			}
+			// 出错的时候路由表保持不变
+			assert.Same(t, before, r.load())
+		})
+	}
+}
+
+func TestRouter_TryAddRoute_RegexError(t *testing.T) {
+	r := NewRouter()
+	err := r.TryAddRoute(http.MethodGet, "/user/:id([0-9)", func(ctx *Context) {})
+	var re *RouteError
+	require.True(t, errors.As(err, &re))
+	assert.Equal(t, RouteInvalid, re.Kind)
+	var se *syntax.Error
+	assert.True(t, errors.As(err, &se))
+	// AddRoute 仍然 panic 原来的值
+	assert.Panics(t, func() {
+		r.AddRoute(http.MethodGet, "/user/:id([0-9)", func(ctx *Context) {})
+	})
+}
+
+func TestRouter_TryAddRoute_CollectAll(t *testing.T) {
+	h := NewHttpServer()
+	api := h.Group("/api")
+	configs := []struct {
+		method string
+		path   string
+	}{
+		{method: http.MethodGet, path: "/user/:id"},
+		{method: http.MethodGet, path: "/user/:uid"},
+		{method: http.MethodGet, path: "user"},
+		{method: http.MethodPost, path: "/user/:id"},
+		{method: http.MethodGet, path: "/user/:id"},
+	}
+	var errs []error
+	for _, c := range configs {
+		if err := api.TryAddRoute(c.method, c.path, func(ctx *Context) {}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	require.Len(t, errs, 3)
+	assert.Contains(t, errs[0].Error(), "web: 路由冲突，参数路由冲突，已有 id，新注册 uid [GET /api/user/:uid]")
+	assert.Contains(t, errs[0].Error(), "冲突的路由 /api/user/:id 位于 ")
+	assert.Contains(t, errs[1].Error(), "web: 路径必须以 [/] 开头 [GET user]")
+	assert.Contains(t, errs[2].Error(), "web: 路由冲突，重复注册[/api/user/:id] [GET /api/user/:id]")
+	assert.Len(t, h.Routes(), 2)
+}
+
+func TestHTTPServer_Validate(t *testing.T) {
+	mockHandler := func(ctx *Context) {}
+	testCases := []struct {
+		name  string
+		setup func(h *HTTPServer)
+
+		wantErrs []*RouteError
+	}{
+		{
+			name: "no problem",
+			setup: func(h *HTTPServer) {
+				h.Get("/user/:id", mockHandler)
+				h.Get("/file/:name.txt", mockHandler)
+				h.Get("/file/:name.json", mockHandler)
+				h.Get("/file/img_:id.png", mockHandler)
+				h.Host(":tenant.example.com").Get("/", mockHandler)
+				h.Host(":tenant.api.example.com").Get("/", mockHandler)
+			},
+		},
+		{
+			name: "duplicate param",
+			setup: func(h *HTTPServer) {
+				h.Get("/user/:id/order/:id", mockHandler)
+				h.Host("api.example.com").Post("/:name/:name.:ext", mockHandler)
+			},
+			wantErrs: []*RouteError{
+				{
+					Kind:    RouteAmbiguous,
+					Method:  http.MethodGet,
+					Pattern: "/user/:id/order/:id",
+					Reason:  "web: 路由歧义，参数名 id 重复出现",
+				},
+				{
+					Kind:    RouteAmbiguous,
+					Host:    "api.example.com",
+					Method:  http.MethodPost,
+					Pattern: "/:name/:name.:ext",
+					Reason:  "web: 路由歧义，参数名 name 重复出现",
+				},
+			},
+		},
+		{
+			name: "mixed siblings",
+			setup: func(h *HTTPServer) {
+				h.Get("/file/:name.txt", mockHandler)
+				h.Get("/file/img_:id", mockHandler)
+				h.Get("/file/img_:id.png", mockHandler)
+			},
+			wantErrs: []*RouteError{
+				{
+					Kind:     RouteAmbiguous,
+					Method:   http.MethodGet,
+					Pattern:  "/file/img_:id",
+					Conflict: "/file/:name.txt",
+					Reason:   "web: 路由歧义，:name.txt 和 img_:id 可能匹配同一段，先注册的 :name.txt 优先",
+				},
+				{
+					Kind:     RouteAmbiguous,
+					Method:   http.MethodGet,
+					Pattern:  "/file/img_:id.png",
+					Conflict: "/file/img_:id",
+					Reason:   "web: 路由歧义，img_:id 和 img_:id.png 可能匹配同一段，先注册的 img_:id 优先",
+				},
+			},
+		},
+		{
+			name: "mixed shadows param",
+			setup: func(h *HTTPServer) {
+				h.Get("/files/:name.:ext", mockHandler)
+				h.Get("/files/:id", mockHandler)
+				h.Get("/docs/:name.:ext/meta", mockHandler)
+				h.Get("/docs/:id/meta", mockHandler)
+				// 后面的段不一样，匹配不到混合路由的时候会回溯到参数路由
+				h.Get("/img/:name.:ext/raw", mockHandler)
+				h.Get("/img/:id", mockHandler)
+			},
+			wantErrs: []*RouteError{
+				{
+					Kind:     RouteAmbiguous,
+					Method:   http.MethodGet,
+					Pattern:  "/files/:id",
+					Conflict: "/files/:name.:ext",
+					Reason:   "web: 路由遮蔽，:name.:ext 比 :id 优先，能匹配 /files/:name.:ext 的路径不会再匹配 /files/:id",
+				},
+				{
+					Kind:     RouteAmbiguous,
+					Method:   http.MethodGet,
+					Pattern:  "/docs/:id/meta",
+					Conflict: "/docs/:name.:ext/meta",
+					Reason:   "web: 路由遮蔽，:name.:ext 比 :id 优先，能匹配 /docs/:name.:ext/meta 的路径不会再匹配 /docs/:id/meta",
+				},
+			},
+		},
+		{
+			name: "mixed shadows regex and wildcard",
+			setup: func(h *HTTPServer) {
+				h.Get("/api/v:version", mockHandler)
+				h.Get("/api/:id(^[0-9a-z]+$)", mockHandler)
+				h.Get("/static/:name.css", mockHandler)
+				h.Get("/static/*filepath", mockHandler)
+			},
+			wantErrs: []*RouteError{
+				{
+					Kind:     RouteAmbiguous,
+					Method:   http.MethodGet,
+					Pattern:  "/api/:id(^[0-9a-z]+$)",
+					Conflict: "/api/v:version",
+					Reason:   "web: 路由遮蔽，v:version 比 :id 优先，能匹配 /api/v:version 的路径不会再匹配 /api/:id(^[0-9a-z]+$)",
+				},
+				{
+					Kind:     RouteAmbiguous,
+					Method:   http.MethodGet,
+					Pattern:  "/static/*filepath",
+					Conflict: "/static/:name.css",
+					Reason:   "web: 路由遮蔽，:name.css 比 *filepath 优先，能匹配 /static/:name.css 的路径不会再匹配 /static/*filepath",
+				},
+			},
+		},
+		{
+			name: "shadowed wildcard host",
+			setup: func(h *HTTPServer) {
+				h.Host(":tenant.example.com").Get("/", mockHandler)
+				h.Host(":shop.example.com").Get("/", mockHandler)
+			},
+			wantErrs: []*RouteError{
+				{
+					Kind:   RouteAmbiguous,
+					Host:   ":shop.example.com",
+					Reason: "web: 虚拟主机歧义，:shop.example.com 会被先注册的 :tenant.example.com 覆盖，永远不会被匹配到",
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHttpServer()
+			tc.setup(h)
+			err := h.Validate()
+			if len(tc.wantErrs) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var errs RouteErrors
+			require.True(t, errors.As(err, &errs))
+			require.Len(t, errs, len(tc.wantErrs))
+			for i, want := range tc.wantErrs {
+				got := errs[i]
+				assert.Equal(t, want.Kind, got.Kind)
+				assert.Equal(t, want.Host, got.Host)
+				assert.Equal(t, want.Method, got.Method)
+				assert.Equal(t, want.Pattern, got.Pattern)
+				assert.Equal(t, want.Conflict, got.Conflict)
+				assert.Equal(t, want.Reason, got.Reason)
+				if want.Pattern != "" {
+					assert.Contains(t, got.Location, "route_error_test.go")
+				}
+			}
+		})
+	}
+}
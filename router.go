@@ -3,7 +3,10 @@ package web
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type NodeType int
@@ -13,113 +16,283 @@ const (
 	PARAMPATH
 	STARPATH
 	REPATH
+	// MIXPATH 参数嵌在静态段中间，例如 :name.:ext 和 v:version
+	MIXPATH
 )
 
+// router 每个 http method 一棵压缩前缀树
+// 静态部分按照字节压缩成公共前缀，例如 /user/home 和 /user/hello 会共享 /user/h 节点
+// 参数、正则、混合和通配符节点只会挂在以 / 结尾的静态节点下面，占据完整的一段
+// 路由表是写时复制的，修改的时候在副本上改完再整体替换，查找不需要加锁，可以在运行期间增删路由
 type router struct {
-	trees map[string]*node
+	// mu 串行化所有修改路由的操作
+	mu sync.Mutex
+	// table 当前生效的 *routeTable
+	table atomic.Value
+	// gen 最近一次修改的编号，见 routeTx
+	gen uint64
 }
 
-func (r *router) FindRoute(method string, path string) (*matchInfo, bool) {
-	root, ok := r.trees[method]
-	if !ok {
-		return nil, false
+// param 匹配出来的路径参数
+type param struct {
+	key string
+	val string
+}
+
+// params 按照匹配顺序记录的路径参数，通过 paramsPool 复用，避免每次查找都分配内存
+type params []param
+
+var paramsPool = sync.Pool{
+	New: func() any {
+		ps := make(params, 0, 8)
+		return &ps
+	},
+}
+
+func getParams() *params {
+	return paramsPool.Get().(*params)
+}
+
+func putParams(ps *params) {
+	*ps = (*ps)[:0]
+	paramsPool.Put(ps)
+}
+
+// fill 把参数写入 m，m 为 nil 并且有参数的时候才会创建新的 map
+func (ps params) fill(m map[string]string) map[string]string {
+	if len(ps) == 0 {
+		return m
 	}
-	if path == "/" {
-		return &matchInfo{n: root}, true
+	if m == nil {
+		m = make(map[string]string, len(ps))
 	}
-	segs := strings.Split(path[1:], "/")
-	cur, params, found, mds := r.findNodeAndMds(root, segs)
-	if !found {
-		return nil, false
+	for _, p := range ps {
+		m[p.key] = p.val
+	}
+	return m
+}
+
+// toMap 没有参数的时候返回 nil
+func (ps params) toMap() map[string]string {
+	if len(ps) == 0 {
+		return nil
 	}
-	res := &matchInfo{
-		n: cur,
+	res := make(map[string]string, len(ps))
+	for _, p := range ps {
+		res[p.key] = p.val
 	}
-	if len(mds) > 0 {
-		res.mds = mds
+	return res
+}
+
+func (r *router) FindRoute(method string, path string) (*matchInfo, bool) {
+	ps := getParams()
+	defer putParams(ps)
+	n, found := r.find(method, path, ps)
+	if !found {
+		return nil, false
 	}
-	if len(params) > 0 {
-		res.params = params
+	return &matchInfo{
+		n:      n,
+		mds:    n.chain,
+		params: ps.toMap(),
+	}, true
+}
+
+// find 查找 path 对应的路由，参数按照顺序写入 ps
+// 静态路由和参数路由的查找过程不会分配内存
+func (r *router) find(method string, path string, ps *params) (*node, bool) {
+	root, ok := r.load().trees[method]
+	if !ok || path == "" || path[0] != '/' {
+		return nil, false
 	}
-	return res, true
+	return root.match(path[1:], ps)
 }
 
-func (r *router) findNodeAndMds(root *node, segs []string) (*node, map[string]string, bool, []Middleware) {
-	queue := []*node{root}
-	var resNode *node
-	var mds []Middleware
-	var params map[string]string
-	if len(root.mds) > 0 {
-		mds = append(mds, root.mds...)
+// allowedMethods 返回能够匹配 path 的所有 http method，按字典序排列
+func (r *router) allowedMethods(path string) []string {
+	var res []string
+	ps := getParams()
+	defer putParams(ps)
+	for method := range r.load().trees {
+		if _, ok := r.find(method, path, ps); ok {
+			res = append(res, method)
+		}
+		*ps = (*ps)[:0]
 	}
-	for _, s := range segs {
-		var cur []*node
-		for _, q := range queue {
-			children, childrenMds := q.findNodeChildren(s)
-			if q.handler != nil && q.nodeType == STARPATH {
-				resNode = q
+	sort.Strings(res)
+	return res
+}
+
+// match 深度优先匹配剩下的 path，n 是已经匹配上的节点
+// 同一层按照 静态 > 混合 > 正则 > 参数 > 通配符 的优先级尝试，某个分支匹配不到的时候回溯尝试下一个
+func (n *node) match(path string, ps *params) (*node, bool) {
+	if path == "" {
+		if n.hasHandler() {
+			return n, true
+		}
+		// 末尾的通配符也匹配空的剩余部分，例如 /s/ 命中 /s/*，这个时候参数的值是空字符串
+		if star := n.starChild; star != nil && star.hasHandler() {
+			if name := star.starParam(); name != "" {
+				*ps = append(*ps, param{key: name, val: ""})
 			}
-			if q.nodeType == PARAMPATH || q.nodeType == REPATH {
-				if params == nil {
-					params = make(map[string]string)
-				}
-				params[q.path] = s
+			return star, true
+		}
+		return nil, false
+	}
+	paramLen := len(*ps)
+	if idx := strings.IndexByte(n.indices, path[0]); idx >= 0 {
+		child := n.children[idx]
+		if strings.HasPrefix(path, child.path) {
+			if res, found := child.match(path[len(child.path):], ps); found {
+				return res, true
 			}
-			cur = append(cur, children...)
-			mds = append(mds, childrenMds...)
-		}
-		queue = cur
-	}
-	if len(queue) > 0 {
-		for i := 0; i < len(queue); i++ {
-			if queue[i].nodeType == PARAMPATH || queue[i].nodeType == REPATH {
-				if params == nil {
-					params = make(map[string]string)
-				}
-				params[queue[i].path] = segs[len(segs)-1]
+		}
+	}
+	if !n.hasDynamic() {
+		return nil, false
+	}
+	seg, rest := path, ""
+	if idx := strings.IndexByte(path, '/'); idx >= 0 {
+		seg, rest = path[:idx], path[idx:]
+	}
+	for _, child := range n.mixChildren {
+		vals, ok := child.mixed.match(seg)
+		if !ok {
+			continue
+		}
+		for i, name := range child.mixed.params {
+			*ps = append(*ps, param{key: name, val: vals[i]})
+		}
+		if res, found := child.match(rest, ps); found {
+			return res, true
+		}
+		*ps = (*ps)[:paramLen]
+	}
+	if n.reChild != nil && n.reChild.matchParam(seg) {
+		*ps = append(*ps, param{key: n.reChild.path, val: seg})
+		if res, found := n.reChild.match(rest, ps); found {
+			return res, true
+		}
+		*ps = (*ps)[:paramLen]
+	}
+	// 参数路由不匹配空的段，例如 /user/ 不会命中 /user/:id
+	if n.pathChild != nil && seg != "" {
+		*ps = append(*ps, param{key: n.pathChild.path, val: seg})
+		if res, found := n.pathChild.match(rest, ps); found {
+			return res, true
+		}
+		*ps = (*ps)[:paramLen]
+	}
+	if star := n.starChild; star != nil {
+		name := star.starParam()
+		// 匿名通配符只匹配一段，后面还可以继续匹配，例如 /a/*/c
+		if name == "" {
+			if res, found := star.match(rest, ps); found {
+				return res, true
 			}
-			if queue[i].handler != nil {
-				return queue[i], params, true, mds
+		}
+		// 末尾的通配符匹配剩下所有的段
+		if star.hasHandler() {
+			if name != "" {
+				*ps = append(*ps, param{key: name, val: path})
 			}
+			return star, true
 		}
 	}
-	if resNode != nil {
-		return resNode, params, true, mds
-	}
-	return nil, nil, false, mds
+	return nil, false
+}
+
+// hasHandler 节点上注册了路由，包括只有带条件的路由的情况
+func (n *node) hasHandler() bool {
+	return n.handler != nil || len(n.conds) > 0
+}
+
+func (n *node) hasDynamic() bool {
+	return n.pathChild != nil || n.reChild != nil || n.starChild != nil || len(n.mixChildren) > 0
+}
+
+// starParam 命名通配符的参数名，例如 *filepath 返回 filepath，匿名通配符返回空字符串
+func (n *node) starParam() string {
+	return n.path[1:]
 }
 
-func (n *node) findNodeMds() []Middleware {
-	if len(n.mds) > 0 {
-		return n.mds
+// findCaseInsensitivePath 忽略静态路径的大小写查找路由，返回修正之后的路径
+func (r *router) findCaseInsensitivePath(method string, path string) (string, bool) {
+	root, ok := r.load().trees[method]
+	if !ok || path == "" || path[0] != '/' {
+		return "", false
 	}
-	return []Middleware{}
+	fixed, found := root.findCaseInsensitive(path[1:], make([]byte, 1, len(path)+1))
+	if !found {
+		return "", false
+	}
+	fixed[0] = '/'
+	return string(fixed), true
 }
-func (n *node) findNodeChildren(s string) ([]*node, []Middleware) {
-	var res []*node
-	var mds []Middleware
-	if n.children != nil {
-		if re, ok := n.children[s]; ok {
-			res = append(res, re)
-			mds = append(mds, re.findNodeMds()...)
+
+func (n *node) findCaseInsensitive(path string, fixed []byte) ([]byte, bool) {
+	if path == "" {
+		return fixed, n.hasHandler()
+	}
+	// 优先完全匹配，再按照注册顺序尝试忽略大小写的匹配
+	if idx := strings.IndexByte(n.indices, path[0]); idx >= 0 {
+		child := n.children[idx]
+		if strings.HasPrefix(path, child.path) {
+			if res, found := child.findCaseInsensitive(path[len(child.path):], append(fixed, child.path...)); found {
+				return res, true
+			}
 		}
 	}
-	if n.reChild != nil && n.reChild.reg.MatchString(s) {
-		res = append(res, n.reChild)
-		mds = append(mds, n.reChild.findNodeMds()...)
+	for _, child := range n.children {
+		if len(path) < len(child.path) || strings.HasPrefix(path, child.path) {
+			continue
+		}
+		if strings.EqualFold(path[:len(child.path)], child.path) {
+			if res, found := child.findCaseInsensitive(path[len(child.path):], append(fixed, child.path...)); found {
+				return res, true
+			}
+		}
 	}
-	if n.pathChild != nil {
-		res = append(res, n.pathChild)
-		mds = append(mds, n.pathChild.findNodeMds()...)
+	if !n.hasDynamic() {
+		return nil, false
 	}
-	if n.starChild != nil {
-		res = append(res, n.starChild)
-		mds = append(mds, n.starChild.findNodeMds()...)
+	seg, rest := path, ""
+	if idx := strings.IndexByte(path, '/'); idx >= 0 {
+		seg, rest = path[:idx], path[idx:]
 	}
-	return res, mds
+	for _, child := range n.mixChildren {
+		if _, ok := child.mixed.match(seg); ok {
+			if res, found := child.findCaseInsensitive(rest, append(fixed, seg...)); found {
+				return res, true
+			}
+		}
+	}
+	if n.reChild != nil && n.reChild.matchParam(seg) {
+		if res, found := n.reChild.findCaseInsensitive(rest, append(fixed, seg...)); found {
+			return res, true
+		}
+	}
+	if n.pathChild != nil && seg != "" {
+		if res, found := n.pathChild.findCaseInsensitive(rest, append(fixed, seg...)); found {
+			return res, true
+		}
+	}
+	if star := n.starChild; star != nil {
+		if star.starParam() == "" {
+			if res, found := star.findCaseInsensitive(rest, append(fixed, seg...)); found {
+				return res, true
+			}
+		}
+		if star.hasHandler() {
+			return append(fixed, path...), true
+		}
+	}
+	return nil, false
 }
 
-func (n *node) parseParam(path string) (string, string, bool) {
+// parseParam 解析参数段，返回参数名、正则表达式和参数类型
+// :id 是普通参数，:id(^[0-9]+$) 是正则参数，:id<int> 是类型参数
+func (n *node) parseParam(path string) (string, string, string) {
 	// 去除 :
 	path = path[1:]
 	// paramName xxx
@@ -127,22 +300,43 @@ func (n *node) parseParam(path string) (string, string, bool) {
 	if len(segs) == 2 {
 		expr := segs[1]
 		if strings.HasSuffix(expr, ")") {
-			return segs[0], expr[:len(expr)-1], true
+			return segs[0], expr[:len(expr)-1], ""
+		}
+	}
+	if strings.HasSuffix(path, ">") {
+		if idx := strings.IndexByte(path, '<'); idx > 0 {
+			return path[:idx], "", path[idx+1 : len(path)-1]
 		}
 	}
-	return path, "", false
+	return path, "", ""
 }
+
 func (n *node) childOrCreateReg(path string, expr string) *node {
+	return n.childOrCreateRegNode(path, expr, nil)
+}
+
+// childOrCreateTyped 类型参数本质上是内置的正则参数，所以同样放在 reChild 上
+func (n *node) childOrCreateTyped(path string, typ string) *node {
+	c, ok := findParamConstraint(typ)
+	if !ok {
+		panic(fmt.Sprintf("web: 未知的参数类型 [%s]", typ))
+	}
+	return n.childOrCreateRegNode(path, c.reg.String(), c)
+}
+
+func (n *node) childOrCreateRegNode(path string, expr string, c *paramConstraint) *node {
 	if n.starChild != nil {
-		panic(fmt.Sprintf("web: 非法路由，已有通配符路由。不允许同时注册通配符路由和正则路由 [%s]", path))
+		panic(conflictWith(n.starChild, fmt.Sprintf("web: 非法路由，已有通配符路由。不允许同时注册通配符路由和正则路由 [%s]", path)))
 	}
 	if n.pathChild != nil {
-		panic(fmt.Sprintf("web: 非法路由，已有路径参数路由。不允许同时注册正则路由和参数路由 [%s]", path))
+		panic(conflictWith(n.pathChild, fmt.Sprintf("web: 非法路由，已有路径参数路由。不允许同时注册正则路由和参数路由 [%s]", path)))
 	}
 	if n.reChild != nil {
-		if n.reChild.reg.String() != expr || n.path != path {
-			panic(fmt.Sprintf("web: 路由冲突，正则路由冲突，已有 %s，新注册 %s", n.reChild.path, path))
+		if n.reChild.reg.String() != expr || n.reChild.path != path || n.reChild.constraint != c {
+			panic(conflictWith(n.reChild, fmt.Sprintf("web: 路由冲突，正则路由冲突，已有 %s，新注册 %s", n.reChild.path, path)))
 		}
+	} else if c != nil {
+		n.reChild = &node{path: path, reg: c.reg, constraint: c, nodeType: REPATH}
 	} else {
 		regExpr, err := regexp.Compile(expr)
 		if err != nil {
@@ -153,16 +347,24 @@ func (n *node) childOrCreateReg(path string, expr string) *node {
 	return n.reChild
 }
 
+// matchParam 判断正则参数或者类型参数能否匹配
+func (n *node) matchParam(s string) bool {
+	if n.constraint != nil {
+		return n.constraint.match(s)
+	}
+	return n.reg.MatchString(s)
+}
+
 func (n *node) childOrCreateParam(path string) *node {
 	if n.reChild != nil {
-		panic(fmt.Sprintf("web: 非法路由，已有正则路由。不允许同时注册正则路由和参数路由 [%s]", path))
+		panic(conflictWith(n.reChild, fmt.Sprintf("web: 非法路由，已有正则路由。不允许同时注册正则路由和参数路由 [%s]", path)))
 	}
 	if n.starChild != nil {
-		panic(fmt.Sprintf("web: 非法路由，已有通配符路由。不允许同时注册通配符路由和参数路由 [%s]", path))
+		panic(conflictWith(n.starChild, fmt.Sprintf("web: 非法路由，已有通配符路由。不允许同时注册通配符路由和参数路由 [%s]", path)))
 	}
 	if n.pathChild != nil {
 		if n.pathChild.path != path {
-			panic(fmt.Sprintf("web: 路由冲突，参数路由冲突，已有 %s，新注册 %s", n.pathChild.path, path))
+			panic(conflictWith(n.pathChild, fmt.Sprintf("web: 路由冲突，参数路由冲突，已有 %s，新注册 %s", n.pathChild.path, path)))
 		}
 	} else {
 		n.pathChild = &node{path: path, nodeType: PARAMPATH}
@@ -170,64 +372,161 @@ func (n *node) childOrCreateParam(path string) *node {
 	return n.pathChild
 }
 
+// childOrCreate 创建或者返回占据完整一段的动态子节点
 func (n *node) childOrCreate(s string) *node {
+	if parts, ok := parseMixedSegment(s); ok {
+		return n.childOrCreateMixed(s, parts)
+	}
 	if s[0] == ':' {
-		path, expr, isReg := n.parseParam(s)
-		if isReg {
+		path, expr, typ := n.parseParam(s)
+		if expr != "" {
 			return n.childOrCreateReg(path, expr)
 		}
+		if typ != "" {
+			return n.childOrCreateTyped(path, typ)
+		}
 
 		return n.childOrCreateParam(path)
 	}
-	if s == "*" {
-		if n.pathChild != nil {
-			panic("web: 不允许同时注册路径参数和通配符匹配跟正则路由,已有参数匹配")
+	if n.pathChild != nil {
+		panic(conflictWith(n.pathChild, "web: 不允许同时注册路径参数和通配符匹配跟正则路由,已有参数匹配"))
+	}
+	if n.reChild != nil {
+		panic(conflictWith(n.reChild, "web: 不允许同时注册路径参数和通配符匹配跟正则路由,已有正则匹配"))
+	}
+	if n.starChild == nil {
+		n.starChild = &node{
+			path:     s,
+			nodeType: STARPATH,
+		}
+	} else if n.starChild.path != s {
+		panic(conflictWith(n.starChild, fmt.Sprintf("web: 路由冲突，通配符路由冲突，已有 %s，新注册 %s", n.starChild.path, s)))
+	}
+	return n.starChild
+}
+
+// childOrCreateStatic 沿着静态前缀往下找，必要的时候拆分已有的节点，经过的节点都会变成可以修改的
+func (tx *routeTx) childOrCreateStatic(n *node, path string) *node {
+	for path != "" {
+		idx := strings.IndexByte(n.indices, path[0])
+		if idx < 0 {
+			child := &node{
+				path:     path,
+				nodeType: FULLPATH,
+				gen:      tx.gen,
+			}
+			n.indices += path[:1]
+			n.children = append(n.children, child)
+			return child
 		}
-		if n.reChild != nil {
-			panic("web: 不允许同时注册路径参数和通配符匹配跟正则路由,已有正则匹配")
+		child := tx.own(n, n.children[idx])
+		l := commonPrefix(child.path, path)
+		if l < len(child.path) {
+			child.split(l)
 		}
-		if n.starChild == nil {
-			n.starChild = &node{
-				path:     s,
-				nodeType: STARPATH,
+		path = path[l:]
+		n = child
+	}
+	return n
+}
+
+// split 在 l 处把节点拆成两个，原来的子节点、handler 和 middleware 都挪到后半部分
+func (n *node) split(l int) {
+	rest := *n
+	rest.path = n.path[l:]
+	*n = node{
+		path:     n.path[:l],
+		nodeType: FULLPATH,
+		indices:  rest.path[:1],
+		children: []*node{&rest},
+		gen:      n.gen,
+	}
+}
+
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+func (r *router) AddRoute(method string, path string, handleFunc HandleFunc, mds ...Middleware) {
+	r.addRoute(method, path, nil, handleFunc, mds...)
+}
+
+// addRoute 注册路由，preds 不为空的时候注册的是带条件的路由
+// 带条件的路由的 middleware 只对自己生效，不会作用到子路由上
+func (r *router) addRoute(method string, path string, preds []Predicate, handleFunc HandleFunc, mds ...Middleware) {
+	r.update(func(tx *routeTx) {
+		tx.addRoute(method, path, preds, handleFunc, mds...)
+	})
+}
+
+func (tx *routeTx) addRoute(method string, path string, preds []Predicate, handleFunc HandleFunc, mds ...Middleware) {
+	if handleFunc == nil {
+		panic("web: handler 不能为 nil")
+	}
+	root := tx.childOrCreatePath(method, path)
+	if len(preds) > 0 {
+		cr := &condRoute{preds: preds, handler: handleFunc, mds: mds}
+		for _, c := range root.conds {
+			if c.key() == cr.key() {
+				panic(conflictWith(root, fmt.Sprintf("web: 路由冲突，重复注册[%s] %s", path, cr.key())))
 			}
 		}
-		return n.starChild
+		root.conds = append(root.conds, cr)
+	} else {
+		if root.handler != nil {
+			panic(conflictWith(root, fmt.Sprintf("web: 路由冲突，重复注册[%s]", path)))
+		}
+		if len(mds) > 0 {
+			tx.ownSubtree(root)
+		}
+		root.handler = handleFunc
+		root.routeMds = mds
 	}
-	if n.children == nil {
-		n.children = map[string]*node{}
+	if root.route == "" {
+		root.loc = callerLocation()
 	}
-	res, ok := n.children[s]
-	if !ok {
-		res = &node{
-			path:     s,
-			nodeType: FULLPATH,
+	root.route = path
+}
+
+// addMiddleware 把 middleware 挂到 path 对应的节点上，命中该节点及其子节点的请求都会执行
+func (r *router) addMiddleware(method string, path string, mds ...Middleware) {
+	r.update(func(tx *routeTx) {
+		n := tx.childOrCreatePath(method, path)
+		tx.ownSubtree(n)
+		n.mds = append(n.mds, mds...)
+	})
+}
+
+// childOrCreatePath 找到 path 对应的节点，路径上不存在的节点会被创建
+func (tx *routeTx) childOrCreatePath(method string, path string) *node {
+	parts := patternParts(path)
+	root := tx.tree(method)
+	for _, p := range parts {
+		root = tx.childOrCreateStatic(root, p.static)
+		if p.dynamic != "" {
+			root = tx.own(root, root.childOrCreate(p.dynamic))
 		}
-		n.children[s] = res
 	}
-	return res
+	return root
 }
 
-func (r *router) AddRoute(method string, path string, handleFunc HandleFunc, mds ...Middleware) {
+// patternPart 路由里面的一段静态部分，以及紧跟在后面的占据完整一段的动态部分
+type patternPart struct {
+	static  string
+	dynamic string
+}
+
+// patternParts 校验并拆分路由，第一个静态部分不包含开头的 /，因为根节点已经包含了
+func patternParts(path string) []patternPart {
 	if path == "" {
 		panic("web: 路径不能为空字符串")
 	}
-	root, ok := r.trees[method]
-	if !ok {
-		root = &node{
-			path:     "/",
-			nodeType: FULLPATH,
-		}
-		r.trees[method] = root
-	}
 	if path == "/" {
-		if root.handler != nil {
-			panic("web: 路由冲突，重复注册[/]")
-		}
-		root.handler = handleFunc
-		root.route = "/"
-		root.mds = mds
-		return
+		return nil
 	}
 	if path[0] != '/' {
 		panic("web: 路径必须以 [/] 开头")
@@ -236,62 +535,112 @@ func (r *router) AddRoute(method string, path string, handleFunc HandleFunc, mds
 		panic("web: 路径不能以 [/] 结尾")
 	}
 	segs := strings.Split(path[1:], "/")
-	for _, s := range segs {
+	var res []patternPart
+	static := ""
+	for i, s := range segs {
 		if s == "" {
 			panic("web: 不能有连续的 //")
 		}
-		child := root.childOrCreate(s)
-		root = child
+		if s[0] == '*' && len(s) > 1 && i != len(segs)-1 {
+			panic(fmt.Sprintf("web: 命名通配符 [%s] 只能出现在路由的最后", s))
+		}
+		if s[0] != ':' && s[0] != '*' && !strings.Contains(s, ":") {
+			static += s
+			if i != len(segs)-1 {
+				static += "/"
+			}
+			continue
+		}
+		res = append(res, patternPart{static: static, dynamic: s})
+		static = ""
+		if i != len(segs)-1 {
+			static = "/"
+		}
 	}
-	if root.handler != nil {
-		panic(fmt.Sprintf("web: 路由冲突，重复注册[%s]", path))
+	if static != "" {
+		res = append(res, patternPart{static: static})
 	}
-	root.handler = handleFunc
-	root.route = path
-	root.mds = mds
+	return res
 }
 
-func (n *node) childOf(seg string) (*node, bool, bool) {
-	if n.children == nil {
-		if n.pathChild != nil {
-			return n.pathChild, true, true
+// buildChain 预先计算命中每个路由时需要执行的路由 middleware 并组装成 compiled，注册路由和 middleware 之后都会重新计算
+// 节点上的 middleware 只对在段的边界上分开的子节点生效，例如 /api 上的 middleware 不会作用到 /apix
+// 只计算 gen 这次修改复制过的节点，其余节点继承的 middleware 没有变化，沿用旧的结果
+func (n *node) buildChain(inherited []Middleware, gen uint64) {
+	own := inherited
+	if len(n.mds) > 0 || len(n.routeMds) > 0 {
+		own = append(inherited[:len(inherited):len(inherited)], n.mds...)
+		own = append(own, n.routeMds...)
+	}
+	n.chain, n.compiled = nil, nil
+	if n.handler != nil {
+		if len(own) > 0 {
+			n.chain = own
 		}
-		if n.reChild != nil {
-			if n.reChild.reg.MatchString(seg) {
-				return n.reChild, false, true
-			}
-			return nil, false, false
+		n.compiled = Chain(n.chain...)(n.handler)
+	}
+	for _, c := range n.conds {
+		c.chain = append(own[:len(own):len(own)], c.mds...)
+		c.compiled = Chain(c.chain...)(c.handler)
+	}
+	for _, child := range n.children {
+		if child.gen != gen {
+			continue
+		}
+		if n.path[len(n.path)-1] == '/' || child.path[0] == '/' {
+			child.buildChain(own, gen)
+		} else {
+			child.buildChain(inherited, gen)
 		}
-		return n.starChild, false, n.starChild != nil
 	}
-	res, ok := n.children[seg]
-	if !ok {
-		if n.pathChild != nil {
-			return n.pathChild, true, true
+	for _, child := range n.mixChildren {
+		if child.gen == gen {
+			child.buildChain(own, gen)
 		}
-		if n.reChild != nil {
-			return n.reChild, false, true
+	}
+	for _, child := range []*node{n.reChild, n.pathChild, n.starChild} {
+		if child != nil && child.gen == gen {
+			child.buildChain(own, gen)
 		}
-		return n.starChild, false, n.starChild != nil
 	}
-	return res, false, true
 }
 
 func NewRouter() router {
-	return router{trees: make(map[string]*node)}
+	return router{}
 }
 
 type node struct {
-	path      string
-	children  map[string]*node
+	// path 静态节点是压缩之后的公共前缀，参数节点是参数名，通配符和混合节点是路由里面对应的段
+	path string
+	// indices 静态子节点 path 的首字节，和 children 一一对应
+	indices   string
+	children  []*node
 	handler   HandleFunc
 	starChild *node
 	pathChild *node
 	nodeType  NodeType
 	reChild   *node
 	reg       *regexp.Regexp
-	mds       []Middleware
-	route     string
+	// constraint 类型参数的约束，只有类型参数节点才有
+	constraint *paramConstraint
+	// mixChildren 参数嵌在段中间的子节点，按照注册顺序匹配
+	mixChildren []*node
+	mixed       *mixedSeg
+	// conds 带条件的路由，路径匹配之后按照注册顺序判断条件
+	conds []*condRoute
+	// mds 挂在前缀上的 middleware，例如分组的 middleware
+	mds []Middleware
+	// routeMds 注册路由时指定的 middleware，同样会作用到子路由上
+	routeMds []Middleware
+	// chain 命中当前路由时需要执行的全部路由 middleware，包括祖先节点上的
+	chain []Middleware
+	// compiled 套上 chain 之后的 handler，请求进来的时候直接调用
+	compiled HandleFunc
+	route    string
+	// loc 注册路由的代码位置，用于报告冲突
+	loc string
+	// gen 创建或者复制这个节点的那次修改的编号，见 routeTx
+	gen uint64
 }
 
 type matchInfo struct {
@@ -3,6 +3,7 @@ package web
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"net/http"
 	"reflect"
 	"testing"
@@ -66,87 +67,90 @@ func TestRouter_AddRoute(t *testing.T) {
 	for _, route := range testRoutes {
 		r.AddRoute(route.method, route.path, mockHandler)
 	}
-	wantRouter := &router{
-		trees: map[string]*node{
-			http.MethodGet: &node{
-				path:    "/",
+	wantTrees := map[string]*node{
+		http.MethodGet: &node{
+			path:    "/",
+			handler: mockHandler,
+			children: []*node{
+				&node{
+					path:    "user",
+					handler: mockHandler,
+					children: []*node{
+						&node{
+							path:    "/home",
+							handler: mockHandler,
+						},
+					},
+				},
+				&node{
+					path:    "order/detail",
+					handler: mockHandler,
+				},
+			},
+		},
+		http.MethodPost: &node{
+			path: "/",
+			starChild: &node{
+				path:    "*",
 				handler: mockHandler,
-				children: map[string]*node{
-					"user": &node{
-						path:    "user",
-						handler: mockHandler,
-						children: map[string]*node{
-							"home": &node{
-								path:    "home",
-								handler: mockHandler,
+			},
+			children: []*node{
+				&node{
+					path: "order/",
+					children: []*node{
+						&node{
+							path:    "create",
+							handler: mockHandler,
+							children: []*node{
+								&node{
+									path: "/",
+									starChild: &node{
+										path:    "*",
+										handler: mockHandler,
+									},
+								},
 							},
 						},
 					},
-					"order": &node{
-						path: "order",
-						children: map[string]*node{
-							"detail": &node{
-								path:    "detail",
+					starChild: &node{
+						path: "*",
+						children: []*node{
+							&node{
+								path:    "/create",
 								handler: mockHandler,
 							},
 						},
 					},
 				},
-			},
-			http.MethodPost: &node{
-				path: "/",
-				starChild: &node{
-					path:    "*",
+				&node{
+					path:    "login",
 					handler: mockHandler,
-				},
-				children: map[string]*node{
-					"order": &node{
-						path: "order",
-						children: map[string]*node{
-							"create": &node{
-								path:    "create",
+					children: []*node{
+						&node{
+							path: "/",
+							pathChild: &node{
+								path:    "id",
 								handler: mockHandler,
-								starChild: &node{
-									path:    "*",
-									handler: mockHandler,
-								},
 							},
 						},
-						starChild: &node{
-							path: "*",
-							children: map[string]*node{
-								"create": &node{
-									path:    "create",
-									handler: mockHandler,
-								},
-							},
-						},
-					},
-					"login": &node{
-						path:    "login",
-						handler: mockHandler,
-						pathChild: &node{
-							path:    "id",
-							handler: mockHandler,
-						},
 					},
 				},
 			},
-			http.MethodTrace: &node{
-				path: "/",
-				children: map[string]*node{
-					"retest": &node{
-						path: "retest",
-						reChild: &node{
-							path:    "id",
-							handler: mockHandler,
-						},
+		},
+		http.MethodTrace: &node{
+			path: "/",
+			children: []*node{
+				&node{
+					path: "retest/",
+					reChild: &node{
+						path:    "id",
+						handler: mockHandler,
 					},
 				},
 			},
 		},
 	}
-	msg, ok := wantRouter.equal(r)
+	msg, ok := equalTrees(wantTrees, r.load().trees)
 	assert.True(t, ok, msg)
 
 	r = NewRouter()
@@ -181,9 +185,9 @@ func TestRouter_AddRoute(t *testing.T) {
 
 }
 
-func (r router) equal(y router) (string, bool) {
-	for k, v := range r.trees {
-		dst, ok := y.trees[k]
+func equalTrees(x map[string]*node, y map[string]*node) (string, bool) {
+	for k, v := range x {
+		dst, ok := y[k]
 		if !ok {
 			return fmt.Sprintf("找不到对应的 http method (%s)", k), false
 		}
@@ -202,13 +206,15 @@ func (n *node) equal(y *node) (string, bool) {
 	if len(n.children) != len(y.children) {
 		return fmt.Sprintf("子节点路径数量不匹配"), false
 	}
-	if (n.starChild != nil && y.starChild == nil) || (n.starChild == nil && y.starChild != nil) {
-		return fmt.Sprintf("通配符节点不匹配"), false
-	}
-	if n.starChild != nil {
-		msg, isOk := n.starChild.equal(y.starChild)
-		if !isOk {
-			return msg, isOk
+	for _, pair := range [][2]*node{{n.starChild, y.starChild}, {n.pathChild, y.pathChild}, {n.reChild, y.reChild}} {
+		if (pair[0] != nil && pair[1] == nil) || (pair[0] == nil && pair[1] != nil) {
+			return fmt.Sprintf("动态子节点不匹配 (%s)", n.path), false
+		}
+		if pair[0] != nil {
+			msg, isOk := pair[0].equal(pair[1])
+			if !isOk {
+				return msg, isOk
+			}
 		}
 	}
 	hHandler := reflect.ValueOf(n.handler)
@@ -216,10 +222,15 @@ func (n *node) equal(y *node) (string, bool) {
 	if hHandler != yHandler {
 		return fmt.Sprintf("handler 不相等"), false
 	}
-	for p, c := range n.children {
-		dst, ok := y.children[p]
-		if !ok {
-			return fmt.Sprintf("子节点路径不存在 (%s)", p), false
+	for _, c := range n.children {
+		var dst *node
+		for _, yc := range y.children {
+			if yc.path == c.path {
+				dst = yc
+			}
+		}
+		if dst == nil {
+			return fmt.Sprintf("子节点路径不存在 (%s)", c.path), false
 		}
 		msg, isOk := c.equal(dst)
 		if !isOk {
@@ -299,6 +310,10 @@ func TestRouter_FindRoute(t *testing.T) {
 			method: http.MethodTrace,
 			path:   "/retest/:id(re.+)",
 		},
+		{
+			method: http.MethodGet,
+			path:   "/s/*",
+		},
 		{
 			method:  http.MethodGet,
 			path:    "/a/b/c/d/*",
@@ -350,7 +365,7 @@ func TestRouter_FindRoute(t *testing.T) {
 			path:      "/order/detail",
 			wantFound: true,
 			wantNode: &node{
-				path:    "detail",
+				path:    "order/detail",
 				handler: mockHandler,
 			},
 		},
@@ -384,7 +399,7 @@ func TestRouter_FindRoute(t *testing.T) {
 			path:      "/order/adsf/create",
 			wantFound: true,
 			wantNode: &node{
-				path:    "create",
+				path:    "/create",
 				handler: mockHandler,
 			},
 		},
@@ -411,6 +426,16 @@ func TestRouter_FindRoute(t *testing.T) {
 				handler: mockHandler,
 			},
 		},
+		{
+			name:      "star empty rest",
+			method:    http.MethodGet,
+			path:      "/s/",
+			wantFound: true,
+			wantNode: &node{
+				path:    "*",
+				handler: mockHandler,
+			},
+		},
 		{
 			name:      "rz retest",
 			method:    http.MethodTrace,
@@ -450,7 +475,7 @@ func TestRouter_FindRoute(t *testing.T) {
 			path:      "/c/b/c/d/e",
 			wantFound: true,
 			wantNode: &node{
-				path:    "e",
+				path:    "/b/c/d/e",
 				handler: sencodHandler,
 			},
 		},
@@ -460,7 +485,7 @@ func TestRouter_FindRoute(t *testing.T) {
 			path:      "/a/m/c/m/e",
 			wantFound: true,
 			wantNode: &node{
-				path:    "e",
+				path:    "/e",
 				handler: thirdHandler,
 			},
 		},
@@ -472,25 +497,10 @@ func TestRouter_FindRoute(t *testing.T) {
 			wantNode: &node{
 				path:    "*",
 				handler: mockHandler,
-				children: map[string]*node{
-					"b": &node{
-						path: "b",
-						children: map[string]*node{
-							"c": &node{
-								path: "c",
-								children: map[string]*node{
-									"d": &node{
-										path: "d",
-										children: map[string]*node{
-											"e": &node{
-												path:    "e",
-												handler: sencodHandler,
-											},
-										},
-									},
-								},
-							},
-						},
+				children: []*node{
+					&node{
+						path:    "/b/c/d/e",
+						handler: sencodHandler,
 					},
 				},
 			},
@@ -559,7 +569,7 @@ func TestRouter_FindRoute_Middleware(t *testing.T) {
 			name:     "star middleware",
 			method:   http.MethodGet,
 			path:     "/a/b/m",
-			wantResp: "a*a:d",
+			wantResp: "a:d",
 		},
 		{
 			name:     "star middleware",
@@ -571,7 +581,13 @@ func TestRouter_FindRoute_Middleware(t *testing.T) {
 			name:     "star middleware",
 			method:   http.MethodGet,
 			path:     "/a/b/c",
-			wantResp: "a*abca:da*d",
+			wantResp: "abc",
+		},
+		{
+			name:     "star then static",
+			method:   http.MethodGet,
+			path:     "/a/x/c",
+			wantResp: "a*a*d",
 		},
 	}
 	for _, tc := range testCases {
@@ -592,3 +608,369 @@ func TestRouter_FindRoute_Middleware(t *testing.T) {
 		})
 	}
 }
+
+func TestRouter_FindRoute_Typed(t *testing.T) {
+	r := NewRouter()
+	r.AddRoute(http.MethodGet, "/user/:id<int>", mockHandler)
+	r.AddRoute(http.MethodGet, "/user/:id<int>/profile", mockHandler)
+	r.AddRoute(http.MethodGet, "/order/:oid<uuid>", mockHandler)
+	r.AddRoute(http.MethodGet, "/tag/:slug<alpha>", mockHandler)
+	r.AddRoute(http.MethodGet, "/report/:day<date>", mockHandler)
+
+	testCases := []struct {
+		name      string
+		path      string
+		wantFound bool
+		wantRoute string
+		params    map[string]string
+	}{
+		{
+			name:      "int",
+			path:      "/user/123",
+			wantFound: true,
+			wantRoute: "/user/:id<int>",
+			params:    map[string]string{"id": "123"},
+		},
+		{
+			name:      "int child",
+			path:      "/user/-1/profile",
+			wantFound: true,
+			wantRoute: "/user/:id<int>/profile",
+			params:    map[string]string{"id": "-1"},
+		},
+		{
+			name: "not int",
+			path: "/user/abc",
+		},
+		{
+			name: "int overflow",
+			path: "/user/99999999999999999999",
+		},
+		{
+			name:      "uuid",
+			path:      "/order/3f2504e0-4f89-11d3-9a0c-0305e82c3301",
+			wantFound: true,
+			wantRoute: "/order/:oid<uuid>",
+			params:    map[string]string{"oid": "3f2504e0-4f89-11d3-9a0c-0305e82c3301"},
+		},
+		{
+			name: "not uuid",
+			path: "/order/3f2504e0",
+		},
+		{
+			name:      "alpha",
+			path:      "/tag/golang",
+			wantFound: true,
+			wantRoute: "/tag/:slug<alpha>",
+			params:    map[string]string{"slug": "golang"},
+		},
+		{
+			name: "not alpha",
+			path: "/tag/go-lang",
+		},
+		{
+			name:      "date",
+			path:      "/report/2023-02-28",
+			wantFound: true,
+			wantRoute: "/report/:day<date>",
+			params:    map[string]string{"day": "2023-02-28"},
+		},
+		{
+			name: "invalid date",
+			path: "/report/2023-02-30",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			info, found := r.FindRoute(http.MethodGet, tc.path)
+			if found && info.n.handler == nil {
+				found = false
+			}
+			assert.Equal(t, tc.wantFound, found)
+			if !found {
+				return
+			}
+			assert.Equal(t, tc.wantRoute, info.n.route)
+			assert.Equal(t, tc.params, info.params)
+		})
+	}
+
+	assert.PanicsWithValue(t, "web: 未知的参数类型 [money]", func() {
+		r.AddRoute(http.MethodGet, "/price/:val<money>", mockHandler)
+	})
+	assert.PanicsWithValue(t, "web: 路由冲突，正则路由冲突，已有 id，新注册 uid", func() {
+		r.AddRoute(http.MethodGet, "/user/:uid<int>/orders", mockHandler)
+	})
+	assert.PanicsWithValue(t, "web: 路由冲突，正则路由冲突，已有 id，新注册 id", func() {
+		r.AddRoute(http.MethodGet, "/user/:id<alpha>/orders", mockHandler)
+	})
+}
+
+func TestRouter_FindRoute_Mixed(t *testing.T) {
+	r := NewRouter()
+	r.AddRoute(http.MethodGet, "/static/*filepath", mockHandler)
+	r.AddRoute(http.MethodGet, "/files/:name.:ext", mockHandler)
+	r.AddRoute(http.MethodGet, "/v:version<int>/users", mockHandler)
+	r.AddRoute(http.MethodGet, "/avatar/user-:id.png", mockHandler)
+
+	testCases := []struct {
+		name      string
+		path      string
+		wantFound bool
+		wantRoute string
+		params    map[string]string
+	}{
+		{
+			name:      "named star one segment",
+			path:      "/static/a.css",
+			wantFound: true,
+			wantRoute: "/static/*filepath",
+			params:    map[string]string{"filepath": "a.css"},
+		},
+		{
+			name:      "named star multi segments",
+			path:      "/static/css/a/b.css",
+			wantFound: true,
+			wantRoute: "/static/*filepath",
+			params:    map[string]string{"filepath": "css/a/b.css"},
+		},
+		{
+			name:      "named star empty rest",
+			path:      "/static/",
+			wantFound: true,
+			wantRoute: "/static/*filepath",
+			params:    map[string]string{"filepath": ""},
+		},
+		{
+			name:      "name and ext",
+			path:      "/files/a.b.c",
+			wantFound: true,
+			wantRoute: "/files/:name.:ext",
+			params:    map[string]string{"name": "a.b", "ext": "c"},
+		},
+		{
+			name: "no ext",
+			path: "/files/abc",
+		},
+		{
+			name:      "prefix with typed param",
+			path:      "/v2/users",
+			wantFound: true,
+			wantRoute: "/v:version<int>/users",
+			params:    map[string]string{"version": "2"},
+		},
+		{
+			name: "prefix with invalid typed param",
+			path: "/vx/users",
+		},
+		{
+			name:      "prefix and suffix",
+			path:      "/avatar/user-12.png",
+			wantFound: true,
+			wantRoute: "/avatar/user-:id.png",
+			params:    map[string]string{"id": "12"},
+		},
+		{
+			name: "suffix not match",
+			path: "/avatar/user-12.jpg",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			info, found := r.FindRoute(http.MethodGet, tc.path)
+			if found && info.n.handler == nil {
+				found = false
+			}
+			assert.Equal(t, tc.wantFound, found)
+			if !found {
+				return
+			}
+			assert.Equal(t, tc.wantRoute, info.n.route)
+			assert.Equal(t, tc.params, info.params)
+		})
+	}
+
+	assert.PanicsWithValue(t, "web: 命名通配符 [*filepath] 只能出现在路由的最后", func() {
+		r.AddRoute(http.MethodGet, "/assets/*filepath/raw", mockHandler)
+	})
+	assert.PanicsWithValue(t, "web: 路由冲突，通配符路由冲突，已有 *filepath，新注册 *path", func() {
+		r.AddRoute(http.MethodGet, "/static/*path", mockHandler)
+	})
+	assert.PanicsWithValue(t, "web: 非法路由，相邻的参数之间必须有分隔字符 [:a:b]", func() {
+		r.AddRoute(http.MethodGet, "/x/:a:b", mockHandler)
+	})
+}
+
+func TestRouter_FindRoute_Backtrack(t *testing.T) {
+	var mdsBuilder = func(i byte) Middleware {
+		return func(next HandleFunc) HandleFunc {
+			return func(context *Context) {
+				context.RespData = append(context.RespData, i)
+				next(context)
+			}
+		}
+	}
+	r := NewRouter()
+	r.addMiddleware(http.MethodGet, "/user/new", mdsBuilder('n'))
+	r.AddRoute(http.MethodGet, "/user/new/edit", mockHandler, mdsBuilder('e'))
+	r.AddRoute(http.MethodGet, "/user/:id/profile", mockHandler, mdsBuilder('p'))
+	r.AddRoute(http.MethodGet, "/user/:id/orders/:oid<int>", mockHandler)
+	r.AddRoute(http.MethodGet, "/user/:id/*", mockHandler, mdsBuilder('*'))
+
+	testCases := []struct {
+		name      string
+		path      string
+		wantRoute string
+		params    map[string]string
+		wantResp  string
+	}{
+		{
+			name:      "static",
+			path:      "/user/new/edit",
+			wantRoute: "/user/new/edit",
+			wantResp:  "ne",
+		},
+		{
+			name:      "backtrack to param",
+			path:      "/user/new/profile",
+			wantRoute: "/user/:id/profile",
+			params:    map[string]string{"id": "new"},
+			wantResp:  "p",
+		},
+		{
+			name:      "backtrack to star",
+			path:      "/user/new/orders/abc",
+			wantRoute: "/user/:id/*",
+			params:    map[string]string{"id": "new"},
+			wantResp:  "*",
+		},
+		{
+			name:      "typed",
+			path:      "/user/new/orders/12",
+			wantRoute: "/user/:id/orders/:oid<int>",
+			params:    map[string]string{"id": "new", "oid": "12"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mi, found := r.FindRoute(http.MethodGet, tc.path)
+			require.True(t, found)
+			assert.Equal(t, tc.wantRoute, mi.n.route)
+			assert.Equal(t, tc.params, mi.params)
+			ctx := &Context{}
+			Chain(mi.mds...)(func(ctx *Context) {})(ctx)
+			assert.Equal(t, tc.wantResp, string(ctx.RespData))
+		})
+	}
+}
+
+func benchRouter() *router {
+	r := NewRouter()
+	for _, path := range []string{
+		"/",
+		"/user",
+		"/user/home",
+		"/user/:id",
+		"/user/:id/profile",
+		"/order/detail",
+		"/order/:oid<int>/items",
+		"/static/*filepath",
+		"/api/v1/users/:id/orders/:oid",
+	} {
+		r.AddRoute(http.MethodGet, path, mockHandler)
+	}
+	return &r
+}
+
+func TestRouter_FindAllocs(t *testing.T) {
+	r := benchRouter()
+	for _, path := range []string{"/user/home", "/user/123/profile", "/api/v1/users/1/orders/2", "/static/css/a.css"} {
+		allocs := testing.AllocsPerRun(100, func() {
+			ps := getParams()
+			_, found := r.find(http.MethodGet, path, ps)
+			putParams(ps)
+			require.True(t, found)
+		})
+		assert.Equal(t, float64(0), allocs, path)
+	}
+}
+
+func BenchmarkRouter_FindStatic(b *testing.B) {
+	r := benchRouter()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ps := getParams()
+		r.find(http.MethodGet, "/user/home", ps)
+		putParams(ps)
+	}
+}
+
+func BenchmarkRouter_FindParam(b *testing.B) {
+	r := benchRouter()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ps := getParams()
+		r.find(http.MethodGet, "/api/v1/users/1/orders/2", ps)
+		putParams(ps)
+	}
+}
+
+func BenchmarkRouter_FindTyped(b *testing.B) {
+	r := benchRouter()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ps := getParams()
+		r.find(http.MethodGet, "/order/42/items", ps)
+		putParams(ps)
+	}
+}
+
+func BenchmarkRouter_FindRoute(b *testing.B) {
+	r := benchRouter()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.FindRoute(http.MethodGet, "/user/123/profile")
+	}
+}
+
+func TestRouter_BuildChain(t *testing.T) {
+	var mdsBuilder = func(i byte) Middleware {
+		return func(next HandleFunc) HandleFunc {
+			return func(context *Context) {
+				context.RespData = append(context.RespData, i)
+				next(context)
+			}
+		}
+	}
+	r := NewRouter()
+	r.addMiddleware(http.MethodGet, "/", mdsBuilder('r'))
+	r.AddRoute(http.MethodGet, "/api/users", mockHandler)
+	r.addMiddleware(http.MethodGet, "/api", mdsBuilder('a'))
+	r.AddRoute(http.MethodGet, "/apix", mockHandler, mdsBuilder('x'))
+	// 拆分 /api 节点之后 middleware 仍然挂在 /api 上
+	r.AddRoute(http.MethodGet, "/ap", mockHandler)
+	r.AddRoute(http.MethodGet, "/api/:id", mockHandler, mdsBuilder(':'))
+
+	testCases := []struct {
+		path     string
+		wantResp string
+	}{
+		{path: "/api/users", wantResp: "ra"},
+		{path: "/apix", wantResp: "rx"},
+		{path: "/ap", wantResp: "r"},
+		{path: "/api/1", wantResp: "ra:"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			mi, found := r.FindRoute(http.MethodGet, tc.path)
+			require.True(t, found)
+			ctx := &Context{}
+			Chain(mi.mds...)(func(ctx *Context) {})(ctx)
+			assert.Equal(t, tc.wantResp, string(ctx.RespData))
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+func (t NodeType) String() string {
+	switch t {
+	case FULLPATH:
+		return "static"
+	case PARAMPATH:
+		return "param"
+	case STARPATH:
+		return "wildcard"
+	case REPATH:
+		return "regex"
+	case MIXPATH:
+		return "mixed"
+	default:
+		return "unknown"
+	}
+}
+
+func (t NodeType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// RouteInfo 已注册路由的描述
+type RouteInfo struct {
+	// Host 虚拟主机，默认路由为空
+	Host    string `json:"host,omitempty"`
+	Method  string `json:"method"`
+	Pattern string `json:"pattern"`
+	Name    string `json:"name,omitempty"`
+	// NodeType 路由最后一段的类型
+	NodeType NodeType `json:"node_type"`
+	Handler  string   `json:"handler"`
+	// Middlewares 命中该路由时会执行的路由 middleware 数量，包括分组挂在前缀上的，不包括全局的
+	Middlewares int `json:"middlewares"`
+	// Predicates 带条件的路由的条件
+	Predicates []string `json:"predicates,omitempty"`
+}
+
+// Routes 返回所有已注册的路由，按照路径和 method 排序
+func (r *router) Routes() []RouteInfo {
+	t := r.load()
+	names := make(map[string]string, len(t.names))
+	for name, nr := range t.names {
+		names[nr.pattern] = name
+	}
+	var res []RouteInfo
+	for method, root := range t.trees {
+		root.walk(func(n *node) {
+			if n.handler != nil {
+				res = append(res, RouteInfo{
+					Method:      method,
+					Pattern:     n.route,
+					Name:        names[n.route],
+					NodeType:    n.nodeType,
+					Handler:     handlerName(n.handler),
+					Middlewares: len(n.chain),
+				})
+			}
+			for _, c := range n.conds {
+				preds := make([]string, len(c.preds))
+				for i, p := range c.preds {
+					preds[i] = p.name
+				}
+				res = append(res, RouteInfo{
+					Method:      method,
+					Pattern:     n.route,
+					Name:        names[n.route],
+					NodeType:    n.nodeType,
+					Handler:     handlerName(c.handler),
+					Middlewares: len(c.chain),
+					Predicates:  preds,
+				})
+			}
+		})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Pattern != res[j].Pattern {
+			return res[i].Pattern < res[j].Pattern
+		}
+		return res[i].Method < res[j].Method
+	})
+	return res
+}
+
+// walk 深度优先遍历有 handler 的节点
+func (n *node) walk(fn func(n *node)) {
+	if n.hasHandler() {
+		fn(n)
+	}
+	for _, child := range n.children {
+		child.walk(fn)
+	}
+	for _, child := range n.mixChildren {
+		child.walk(fn)
+	}
+	for _, child := range []*node{n.reChild, n.pathChild, n.starChild} {
+		if child != nil {
+			child.walk(fn)
+		}
+	}
+}
+
+func handlerName(handleFunc HandleFunc) string {
+	if handleFunc == nil {
+		return ""
+	}
+	fn := runtime.FuncForPC(reflect.ValueOf(handleFunc).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	return fn.Name()
+}
+
+var routesTpl = template.Must(template.New("routes").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>routes</title></head>
+<body>
+<table border="1" cellspacing="0" cellpadding="4">
+<tr><th>Host</th><th>Method</th><th>Pattern</th><th>Name</th><th>Type</th><th>Handler</th><th>Middlewares</th><th>Predicates</th></tr>
+{{- range . }}
+<tr><td>{{ .Host }}</td><td>{{ .Method }}</td><td>{{ .Pattern }}</td><td>{{ .Name }}</td><td>{{ .NodeType }}</td><td>{{ .Handler }}</td><td>{{ .Middlewares }}</td><td>{{ range $i, $p := .Predicates }}{{ if $i }} {{ end }}{{ $p }}{{ end }}</td></tr>
+{{- end }}
+</table>
+</body>
+</html>`))
+
+// RoutesHandler 以表格的形式输出当前注册的所有路由，方便排查线上的服务到底提供了哪些接口
+// 默认输出 JSON，带上 ?format=html 或者 Accept 里面有 text/html 的时候输出 HTML
+// 例如 h.Get("/debug/routes", h.RoutesHandler())
+func (h *HTTPServer) RoutesHandler() HandleFunc {
+	return func(ctx *Context) {
+		routes := h.Routes()
+		format, _ := ctx.QueryValue("format").AsString()
+		if format == "html" || (format == "" && strings.Contains(ctx.Req.Header.Get("Accept"), "text/html")) {
+			buf := &bytes.Buffer{}
+			if err := routesTpl.Execute(buf, routes); err != nil {
+				ctx.RespStatusCode = http.StatusInternalServerError
+				ctx.RespData = []byte(err.Error())
+				return
+			}
+			ctx.Resp.Header().Set("Content-Type", "text/html; charset=utf-8")
+			ctx.RespStatusCode = http.StatusOK
+			ctx.RespData = buf.Bytes()
+			return
+		}
+		data, err := json.Marshal(routes)
+		if err != nil {
+			ctx.RespStatusCode = http.StatusInternalServerError
+			ctx.RespData = []byte(err.Error())
+			return
+		}
+		ctx.Resp.Header().Set("Content-Type", "application/json")
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = data
+	}
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func routesTestHandler(ctx *Context) {}
+
+func TestRouter_Routes(t *testing.T) {
+	var mdl Middleware = func(next HandleFunc) HandleFunc {
+		return next
+	}
+	h := NewHttpServer()
+	api := h.Group("/api", mdl)
+	api.Get("/user/:id", routesTestHandler, mdl)
+	api.AddNamedRoute("order", http.MethodPost, "/order/:id(^[0-9]+$)", routesTestHandler)
+	h.Get("/", routesTestHandler)
+	h.Get("/static/*", routesTestHandler)
+	// /api 上的 middleware 只作用到 /api/ 下面的路由，不会作用到 /apix
+	h.Get("/apix", routesTestHandler)
+	api.When(Header("X-Beta", "1")).Get("/beta", routesTestHandler, mdl)
+
+	routes := h.Routes()
+	assert.Equal(t, []RouteInfo{
+		{
+			Method:      http.MethodGet,
+			Pattern:     "/",
+			NodeType:    FULLPATH,
+			Handler:     "routing.routesTestHandler",
+			Middlewares: 0,
+		},
+		{
+			Method:      http.MethodGet,
+			Pattern:     "/api/beta",
+			NodeType:    FULLPATH,
+			Handler:     "routing.routesTestHandler",
+			Middlewares: 2,
+			Predicates:  []string{"Header(X-Beta=1)"},
+		},
+		{
+			Method:      http.MethodPost,
+			Pattern:     "/api/order/:id(^[0-9]+$)",
+			Name:        "order",
+			NodeType:    REPATH,
+			Handler:     "routing.routesTestHandler",
+			Middlewares: 1,
+		},
+		{
+			Method:      http.MethodGet,
+			Pattern:     "/api/user/:id",
+			NodeType:    PARAMPATH,
+			Handler:     "routing.routesTestHandler",
+			Middlewares: 2,
+		},
+		{
+			Method:      http.MethodGet,
+			Pattern:     "/apix",
+			NodeType:    FULLPATH,
+			Handler:     "routing.routesTestHandler",
+			Middlewares: 0,
+		},
+		{
+			Method:      http.MethodGet,
+			Pattern:     "/static/*",
+			NodeType:    STARPATH,
+			Handler:     "routing.routesTestHandler",
+			Middlewares: 0,
+		},
+	}, routes)
+}
+
+func TestHTTPServer_RoutesHandler(t *testing.T) {
+	h := NewHttpServer()
+	h.Get("/debug/routes", h.RoutesHandler())
+	h.Get("/user/:id", routesTestHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/routes", nil)
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	var routes []map[string]any
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &routes))
+	require.Len(t, routes, 2)
+	assert.Equal(t, "/user/:id", routes[1]["pattern"])
+	assert.Equal(t, "param", routes[1]["node_type"])
+
+	req = httptest.NewRequest(http.MethodGet, "/debug/routes?format=html", nil)
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.True(t, strings.Contains(recorder.Body.String(), "<td>/user/:id</td>"))
+}
@@ -0,0 +1,158 @@
+package web
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// segPart 段的组成部分，literal 和 param 只会有一个不为空
+type segPart struct {
+	literal    string
+	param      string
+	constraint *paramConstraint
+}
+
+// mixedSeg 参数嵌在静态段中间的段，例如 :name.:ext 和 v:version
+type mixedSeg struct {
+	parts []segPart
+	reg   *regexp.Regexp
+	// params 按照出现顺序排列的参数名
+	params []string
+	// groups 每个参数在正则里面对应的分组下标
+	groups      []int
+	constraints []*paramConstraint
+}
+
+// match 返回按照出现顺序排列的参数值
+func (m *mixedSeg) match(seg string) ([]string, bool) {
+	sub := m.reg.FindStringSubmatch(seg)
+	if sub == nil {
+		return nil, false
+	}
+	vals := make([]string, len(m.groups))
+	for i, g := range m.groups {
+		vals[i] = sub[g]
+		if c := m.constraints[i]; c != nil && c.validate != nil && !c.validate(vals[i]) {
+			return nil, false
+		}
+	}
+	return vals, true
+}
+
+func (n *node) childOrCreateMixed(s string, parts []segPart) *node {
+	for _, child := range n.mixChildren {
+		if child.path == s {
+			return child
+		}
+	}
+	child := &node{
+		path:     s,
+		nodeType: MIXPATH,
+		mixed:    newMixedSeg(parts),
+	}
+	n.mixChildren = append(n.mixChildren, child)
+	return child
+}
+
+func newMixedSeg(parts []segPart) *mixedSeg {
+	res := &mixedSeg{parts: parts}
+	var sb strings.Builder
+	sb.WriteByte('^')
+	for _, p := range parts {
+		if p.param == "" {
+			sb.WriteString(regexp.QuoteMeta(p.literal))
+			continue
+		}
+		// 用命名分组避免类型参数的正则里面自带的分组打乱下标
+		group := "p" + strconv.Itoa(len(res.params))
+		pattern := ".+"
+		if p.constraint != nil {
+			pattern = p.constraint.pattern
+		}
+		sb.WriteString("(?P<" + group + ">" + pattern + ")")
+		res.params = append(res.params, p.param)
+		res.constraints = append(res.constraints, p.constraint)
+	}
+	sb.WriteByte('$')
+	res.reg = regexp.MustCompile(sb.String())
+	for i := range res.params {
+		res.groups = append(res.groups, res.reg.SubexpIndex("p"+strconv.Itoa(i)))
+	}
+	return res
+}
+
+// parseMixedSegment 解析嵌在静态段中间的参数，完整占据一段的参数不算
+// 参数名只能由字母、数字和下划线组成，后面可以跟 <类型>
+// 多个参数连续的时候，第一个参数会尽可能多地匹配，例如 :name.:ext 匹配 a.b.c 得到 name=a.b，ext=c
+func parseMixedSegment(s string) ([]segPart, bool) {
+	if !strings.Contains(s, ":") || isWholeParam(s) {
+		return nil, false
+	}
+	raw := s
+	var parts []segPart
+	for len(s) > 0 {
+		idx := strings.IndexByte(s, ':')
+		if idx < 0 {
+			parts = append(parts, segPart{literal: s})
+			break
+		}
+		if idx > 0 {
+			parts = append(parts, segPart{literal: s[:idx]})
+		}
+		s = s[idx+1:]
+		nameLen := identLen(s)
+		if nameLen == 0 {
+			panic(fmt.Sprintf("web: 非法路由，参数名不能为空 [%s]", raw))
+		}
+		if len(parts) > 0 && parts[len(parts)-1].param != "" {
+			panic(fmt.Sprintf("web: 非法路由，相邻的参数之间必须有分隔字符 [%s]", raw))
+		}
+		part := segPart{param: s[:nameLen]}
+		s = s[nameLen:]
+		if len(s) > 0 && s[0] == '<' {
+			end := strings.IndexByte(s, '>')
+			if end < 0 {
+				panic(fmt.Sprintf("web: 非法路由，参数类型缺少 > [%s]", raw))
+			}
+			c, ok := findParamConstraint(s[1:end])
+			if !ok {
+				panic(fmt.Sprintf("web: 未知的参数类型 [%s]", s[1:end]))
+			}
+			part.constraint = c
+			s = s[end+1:]
+		}
+		parts = append(parts, part)
+	}
+	return parts, true
+}
+
+// isWholeParam 判断是不是完整占据一段的参数，例如 :id、:id(^[0-9]+$) 和 :id<int>
+func isWholeParam(s string) bool {
+	if s[0] != ':' {
+		return false
+	}
+	nameLen := identLen(s[1:])
+	if nameLen == 0 {
+		return true
+	}
+	rest := s[1+nameLen:]
+	if rest == "" {
+		return true
+	}
+	if rest[0] == '(' && rest[len(rest)-1] == ')' {
+		return true
+	}
+	return rest[0] == '<' && rest[len(rest)-1] == '>' && !strings.Contains(rest, ":")
+}
+
+func identLen(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			return i
+		}
+	}
+	return len(s)
+}
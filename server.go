@@ -1,10 +1,21 @@
 package web
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"net"
 	"net/http"
 	"routing/template"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // 确保一定实现了server接口
@@ -15,6 +26,9 @@ type HandleFunc func(*Context)
 type Server interface {
 	http.Handler
 	Start(addr string) error
+	StartTLS(addr string, certFile string, keyFile string) error
+	// Shutdown 优雅退出，等待正在处理的请求结束之后执行退出钩子
+	Shutdown(ctx context.Context) error
 	AddRoute(method string, path string, handleFunc HandleFunc, mds ...Middleware)
 	FindRoute(method string, path string) (*matchInfo, bool)
 }
@@ -24,6 +38,40 @@ type HTTPServer struct {
 	log       func(msg string, args ...any)
 	ms        []Middleware
 	tplEngine template.TemplateEngine
+	// validator 绑定之后校验请求参数，validationStatus 是 RespProblem 渲染校验错误时的状态码
+	validator        Validator
+	validationStatus int
+	// codecs BindBody 按照 Content-Type 选择的解码器
+	codecs map[string]Codec
+	// root 套上全局 middleware 和写回响应逻辑之后的入口，创建 HTTPServer 的时候组装好
+	root HandleFunc
+
+	mu     sync.Mutex
+	srv    *http.Server
+	closed bool
+	// starting 正在执行的启动钩子，Shutdown 等它们结束之后才执行退出钩子
+	starting        sync.WaitGroup
+	onStart         []*lifecycleHook
+	onShutdown      []*lifecycleHook
+	shutdownTimeout time.Duration
+
+	h2c                bool
+	certReloadInterval time.Duration
+
+	handleMethodNotAllowed bool
+	autoOptions            bool
+	autoHead               bool
+	// notFoundHandler 和 methodNotAllowedHandler 自定义 404 和 405 的响应，为 nil 的时候使用默认的响应
+	notFoundHandler         HandleFunc
+	methodNotAllowedHandler HandleFunc
+
+	redirectTrailingSlash   bool
+	redirectCleanPath       bool
+	redirectCaseInsensitive bool
+
+	// hostsMu 串行化注册虚拟主机的操作，hosts 当前生效的 *hostTable
+	hostsMu sync.Mutex
+	hosts   atomic.Value
 }
 
 type HTTPServerOption func(server *HTTPServer)
@@ -34,10 +82,22 @@ func NewHttpServer(opts ...HTTPServerOption) *HTTPServer {
 		log: func(msg string, args ...any) {
 			fmt.Printf(msg, args...)
 		},
+		shutdownTimeout:    30 * time.Second,
+		certReloadInterval: 10 * time.Second,
+		// 默认开启 405 处理
+		handleMethodNotAllowed: true,
+		autoOptions:            true,
+		autoHead:               true,
+		validationStatus:       http.StatusUnprocessableEntity,
+		codecs:                 defaultCodecs,
 	}
 	for _, opt := range opts {
 		opt(res)
 	}
+	if fr, ok := res.tplEngine.(template.FuncsRegister); ok {
+		fr.RegisterFuncs(res.templateFuncs())
+	}
+	res.compile()
 	return res
 }
 
@@ -46,27 +106,109 @@ func ServerWithMiddleware(ms ...Middleware) HTTPServerOption {
 		server.ms = ms
 	}
 }
+
+// ServerWithMethodNotAllowed 控制路径存在但是 method 不匹配的时候，是否返回 405
+// 关闭之后统一返回 404
+func ServerWithMethodNotAllowed(enabled bool) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.handleMethodNotAllowed = enabled
+	}
+}
+
+// ServerWithAutoOptions 控制没有注册 OPTIONS 路由的时候，是否自动根据已注册的 method 应答 OPTIONS 请求
+func ServerWithAutoOptions(enabled bool) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.autoOptions = enabled
+	}
+}
+
+// ServerWithAutoHead 控制没有注册 HEAD 路由的时候，是否使用 GET 路由处理 HEAD 请求
+func ServerWithAutoHead(enabled bool) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.autoHead = enabled
+	}
+}
+
 func ServerWithTemplateEngine(tpl template.TemplateEngine) HTTPServerOption {
 	return func(server *HTTPServer) {
 		server.tplEngine = tpl
 	}
 }
 
-func (h *HTTPServer) Get(path string, handleFunc HandleFunc) {
-	h.AddRoute(http.MethodGet, path, handleFunc)
+// anyMethods Any 注册路由时使用的全部 http method
+var anyMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
+func (h *HTTPServer) Get(path string, handleFunc HandleFunc, mds ...Middleware) {
+	h.AddRoute(http.MethodGet, path, handleFunc, mds...)
+}
+
+func (h *HTTPServer) Head(path string, handleFunc HandleFunc, mds ...Middleware) {
+	h.AddRoute(http.MethodHead, path, handleFunc, mds...)
+}
+
+func (h *HTTPServer) Post(path string, handleFunc HandleFunc, mds ...Middleware) {
+	h.AddRoute(http.MethodPost, path, handleFunc, mds...)
+}
+
+func (h *HTTPServer) Put(path string, handleFunc HandleFunc, mds ...Middleware) {
+	h.AddRoute(http.MethodPut, path, handleFunc, mds...)
+}
+
+func (h *HTTPServer) Patch(path string, handleFunc HandleFunc, mds ...Middleware) {
+	h.AddRoute(http.MethodPatch, path, handleFunc, mds...)
+}
+
+func (h *HTTPServer) Delete(path string, handleFunc HandleFunc, mds ...Middleware) {
+	h.AddRoute(http.MethodDelete, path, handleFunc, mds...)
+}
+
+func (h *HTTPServer) Connect(path string, handleFunc HandleFunc, mds ...Middleware) {
+	h.AddRoute(http.MethodConnect, path, handleFunc, mds...)
 }
 
-func (h *HTTPServer) Post(path string, handleFunc HandleFunc) {
-	h.AddRoute(http.MethodPost, path, handleFunc)
+func (h *HTTPServer) Options(path string, handleFunc HandleFunc, mds ...Middleware) {
+	h.AddRoute(http.MethodOptions, path, handleFunc, mds...)
+}
+
+func (h *HTTPServer) Trace(path string, handleFunc HandleFunc, mds ...Middleware) {
+	h.AddRoute(http.MethodTrace, path, handleFunc, mds...)
+}
+
+// Any 为所有的 http method 注册同一个路由
+func (h *HTTPServer) Any(path string, handleFunc HandleFunc, mds ...Middleware) {
+	h.Match(anyMethods, path, handleFunc, mds...)
+}
+
+// Match 为指定的几个 http method 注册同一个路由
+func (h *HTTPServer) Match(methods []string, path string, handleFunc HandleFunc, mds ...Middleware) {
+	for _, method := range methods {
+		h.AddRoute(method, path, handleFunc, mds...)
+	}
 }
 
 // ServeHTTP 处理请求的入口
 func (h *HTTPServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	ctx := &Context{
-		Req:       request,
-		Resp:      writer,
-		tplEngine: h.tplEngine,
-	}
+	ctx := contextPool.Get().(*Context)
+	ctx.Req, ctx.Resp, ctx.tplEngine = request, writer, h.tplEngine
+	ctx.validator, ctx.validationStatus, ctx.codecs = h.validator, h.validationStatus, h.codecs
+	h.root(ctx)
+	// handler panic 的时候不回收，Context 可能还被别的地方引用
+	ctx.reset()
+	contextPool.Put(ctx)
+}
+
+// compile 组装全局 middleware 和写回响应的逻辑，避免每个请求都重新创建一遍
+func (h *HTTPServer) compile() {
 	root := h.Serve
 	if len(h.ms) > 0 {
 		root = Chain(h.ms...)(root)
@@ -74,24 +216,122 @@ func (h *HTTPServer) ServeHTTP(writer http.ResponseWriter, request *http.Request
 	respon := func(next HandleFunc) HandleFunc {
 		return func(context *Context) {
 			next(context)
+			// HEAD 请求不能有响应体，但是要保留 GET 时的 Content-Length
+			if context.Req.Method == http.MethodHead {
+				header := context.Resp.Header()
+				if header.Get("Content-Length") == "" && len(context.RespData) > 0 {
+					header.Set("Content-Length", strconv.Itoa(len(context.RespData)))
+				}
+				context.Resp.WriteHeader(context.RespStatusCode)
+				return
+			}
 			context.Resp.WriteHeader(context.RespStatusCode)
 			context.Resp.Write(context.RespData)
 		}
 	}
-	root = respon(root)
-	root(ctx)
+	h.root = respon(root)
 }
 
 func (h *HTTPServer) Serve(context *Context) {
-	n, isFound := h.FindRoute(context.Req.Method, context.Req.URL.Path)
-	if !isFound || n.n.handler == nil {
-		context.RespStatusCode = http.StatusNotFound
-		context.RespData = []byte("NOT FOUND")
+	ps := getParams()
+	defer putParams(ps)
+	r := h.hostRouter(context.Req.Host, ps)
+	n, isFound := h.findHandler(r, context.Req.Method, context.Req.URL.Path, ps)
+	if !isFound {
+		if target, ok := h.redirectPath(r, context.Req.Method, context.Req.URL.Path); ok {
+			h.redirect(context, target)
+			return
+		}
+		h.handleNoRoute(r, context)
 		return
 	}
-	context.PathParams = n.params
-	context.MatchedRoute = n.n.route
-	Chain(n.mds...)(n.n.handler)(context)
+	context.PathParams = ps.fill(context.PathParams)
+	context.MatchedRoute = n.route
+	if len(n.conds) == 0 {
+		n.compiled(context)
+		return
+	}
+	handler, status := n.selectHandler(context.Req)
+	if handler == nil {
+		context.RespStatusCode = status
+		// 条件不满足导致的 404 和找不到路由一样，使用自定义的 404 处理
+		if status == http.StatusNotFound {
+			if hf := h.notFoundFor(r, context.Req.URL.Path); hf != nil {
+				hf(context)
+				return
+			}
+		}
+		context.RespData = []byte(strings.ToUpper(http.StatusText(status)))
+		return
+	}
+	handler(context)
+}
+
+// findHandler 查找有 handler 的路由，参数写入 ps
+// 没有注册 HEAD 的时候，使用 GET 的处理逻辑，响应体会在写回的时候丢弃
+func (h *HTTPServer) findHandler(r *router, method string, path string, ps *params) (*node, bool) {
+	n, isFound := r.find(method, path, ps)
+	if !isFound && method == http.MethodHead && h.autoHead {
+		n, isFound = r.find(http.MethodGet, path, ps)
+	}
+	return n, isFound
+}
+
+// handleNoRoute 当前 method 下没有匹配的路由
+// OPTIONS 请求直接返回允许的 method；其余请求如果别的 method 下有匹配的路由，返回 405，否则返回 404
+func (h *HTTPServer) handleNoRoute(r *router, context *Context) {
+	if context.Req.Method == http.MethodOptions && h.autoOptions {
+		if allowed := h.allowedMethods(r, context.Req.URL.Path); len(allowed) > 0 {
+			context.Resp.Header().Set("Allow", strings.Join(allowed, ", "))
+			context.RespStatusCode = http.StatusNoContent
+			return
+		}
+	}
+	if h.handleMethodNotAllowed {
+		if allowed := h.allowedMethods(r, context.Req.URL.Path); len(allowed) > 0 {
+			context.Resp.Header().Set("Allow", strings.Join(allowed, ", "))
+			context.RespStatusCode = http.StatusMethodNotAllowed
+			if hf := h.methodNotAllowedFor(r, context.Req.URL.Path); hf != nil {
+				hf(context)
+				return
+			}
+			context.RespData = []byte("METHOD NOT ALLOWED")
+			return
+		}
+	}
+	context.RespStatusCode = http.StatusNotFound
+	if hf := h.notFoundFor(r, context.Req.URL.Path); hf != nil {
+		hf(context)
+		return
+	}
+	context.RespData = []byte("NOT FOUND")
+}
+
+// allowedMethods 在路由树的基础上，加上自动处理的 HEAD 和 OPTIONS
+func (h *HTTPServer) allowedMethods(r *router, path string) []string {
+	allowed := r.allowedMethods(path)
+	if len(allowed) == 0 {
+		return nil
+	}
+	var hasGet, hasHead, hasOptions bool
+	for _, method := range allowed {
+		switch method {
+		case http.MethodGet:
+			hasGet = true
+		case http.MethodHead:
+			hasHead = true
+		case http.MethodOptions:
+			hasOptions = true
+		}
+	}
+	if hasGet && !hasHead && h.autoHead {
+		allowed = append(allowed, http.MethodHead)
+	}
+	if !hasOptions && h.autoOptions {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	sort.Strings(allowed)
+	return allowed
 }
 
 func (h *HTTPServer) Start(addr string) error {
@@ -99,5 +339,87 @@ func (h *HTTPServer) Start(addr string) error {
 	if err != nil {
 		return err
 	}
-	return http.Serve(l, h)
+	return h.serve(l)
+}
+
+func (h *HTTPServer) serve(l net.Listener) error {
+	var handler http.Handler = h
+	if h.h2c {
+		handler = h2c.NewHandler(h, &http2.Server{})
+	}
+	return h.serveWith(l, &http.Server{Handler: handler})
+}
+
+func (h *HTTPServer) serveTLS(l net.Listener, cfg *tls.Config) error {
+	// ServeTLS 会自动在 NextProtos 里面加上 h2，完成 HTTP/2 协商
+	return h.serveWith(l, &http.Server{Handler: h, TLSConfig: cfg})
+}
+
+func (h *HTTPServer) serveWith(l net.Listener, srv *http.Server) error {
+	h.mu.Lock()
+	// 已经调用过 Shutdown，不再执行启动钩子
+	if h.closed {
+		h.mu.Unlock()
+		return l.Close()
+	}
+	h.starting.Add(1)
+	h.mu.Unlock()
+	if err := h.runHooks(context.Background(), h.onStart, true); err != nil {
+		h.starting.Done()
+		_ = l.Close()
+		return err
+	}
+	h.mu.Lock()
+	// 启动钩子执行期间已经调用了 Shutdown
+	closed := h.closed
+	if !closed {
+		h.srv = srv
+	}
+	h.mu.Unlock()
+	h.starting.Done()
+	if closed {
+		return l.Close()
+	}
+	var err error
+	if srv.TLSConfig != nil {
+		err = srv.ServeTLS(l, "", "")
+	} else {
+		err = srv.Serve(l)
+	}
+	// 调用 Shutdown 导致的退出不算错误
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown 停止接收新请求，等待正在处理的请求结束，然后按顺序执行退出钩子
+// 多次调用只有第一次生效
+func (h *HTTPServer) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	h.mu.Unlock()
+	// 等正在执行的启动钩子结束，避免启动钩子和退出钩子同时执行
+	h.starting.Wait()
+	h.mu.Lock()
+	srv := h.srv
+	h.mu.Unlock()
+
+	var err error
+	if srv != nil {
+		err = srv.Shutdown(ctx)
+	}
+	// 即使没能在 ctx 内处理完请求，退出钩子也要执行，此时只受钩子自身的超时控制
+	hookCtx := ctx
+	if ctx.Err() != nil {
+		hookCtx = context.Background()
+	}
+	if hookErr := h.runHooks(hookCtx, h.onShutdown, false); err == nil {
+		err = hookErr
+	}
+	return err
 }
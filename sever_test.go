@@ -1,5 +1,342 @@
 package web
 
-//func TestServer(t *testing.T) {
-//
-//}
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPServer_MethodHelpers(t *testing.T) {
+	var handler HandleFunc = func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = append(ctx.RespData, []byte(ctx.Req.Method)...)
+	}
+	var mdl Middleware = func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			ctx.RespData = append(ctx.RespData, '+')
+			next(ctx)
+		}
+	}
+	h := NewHttpServer()
+	h.Put("/put", handler, mdl)
+	h.Delete("/delete", handler)
+	h.Patch("/patch", handler)
+	h.Options("/options", handler)
+	h.Trace("/trace", handler)
+	h.Head("/head", handler)
+	h.Connect("/connect", handler)
+	h.Any("/any", handler)
+	h.Match([]string{http.MethodGet, http.MethodPost}, "/match", handler, mdl)
+
+	testCases := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode int
+		wantResp string
+	}{
+		{
+			name:     "put with middleware",
+			method:   http.MethodPut,
+			path:     "/put",
+			wantCode: http.StatusOK,
+			wantResp: "+PUT",
+		},
+		{
+			name:     "delete",
+			method:   http.MethodDelete,
+			path:     "/delete",
+			wantCode: http.StatusOK,
+			wantResp: "DELETE",
+		},
+		{
+			name:     "patch",
+			method:   http.MethodPatch,
+			path:     "/patch",
+			wantCode: http.StatusOK,
+			wantResp: "PATCH",
+		},
+		{
+			name:     "options",
+			method:   http.MethodOptions,
+			path:     "/options",
+			wantCode: http.StatusOK,
+			wantResp: "OPTIONS",
+		},
+		{
+			name:     "trace",
+			method:   http.MethodTrace,
+			path:     "/trace",
+			wantCode: http.StatusOK,
+			wantResp: "TRACE",
+		},
+		{
+			name:     "any",
+			method:   http.MethodPatch,
+			path:     "/any",
+			wantCode: http.StatusOK,
+			wantResp: "PATCH",
+		},
+		{
+			name:     "match",
+			method:   http.MethodPost,
+			path:     "/match",
+			wantCode: http.StatusOK,
+			wantResp: "+POST",
+		},
+		{
+			name:     "not match",
+			method:   http.MethodPut,
+			path:     "/match",
+			wantCode: http.StatusMethodNotAllowed,
+			wantResp: "METHOD NOT ALLOWED",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+		})
+	}
+
+	for _, method := range anyMethods {
+		_, ok := h.FindRoute(method, "/any")
+		assert.True(t, ok, method)
+	}
+}
+
+func TestHTTPServer_MethodNotAllowed(t *testing.T) {
+	var handler HandleFunc = func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+	}
+	testCases := []struct {
+		name      string
+		opts      []HTTPServerOption
+		method    string
+		path      string
+		wantCode  int
+		wantAllow string
+	}{
+		{
+			name:      "method not allowed",
+			method:    http.MethodPut,
+			path:      "/user/123",
+			wantCode:  http.StatusMethodNotAllowed,
+			wantAllow: "DELETE, GET, HEAD, OPTIONS",
+		},
+		{
+			name:      "path not found",
+			method:    http.MethodPut,
+			path:      "/order",
+			wantCode:  http.StatusNotFound,
+			wantAllow: "",
+		},
+		{
+			name:      "disabled",
+			opts:      []HTTPServerOption{ServerWithMethodNotAllowed(false)},
+			method:    http.MethodPut,
+			path:      "/user/123",
+			wantCode:  http.StatusNotFound,
+			wantAllow: "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHttpServer(tc.opts...)
+			h.Get("/user/:id", handler)
+			h.Delete("/user/:id", handler)
+			h.Post("/user", handler)
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantAllow, recorder.Header().Get("Allow"))
+		})
+	}
+}
+
+func TestHTTPServer_AutoOptionsAndHead(t *testing.T) {
+	var handler HandleFunc = func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("hello")
+	}
+	testCases := []struct {
+		name       string
+		opts       []HTTPServerOption
+		method     string
+		path       string
+		wantCode   int
+		wantAllow  string
+		wantLength string
+		wantResp   string
+	}{
+		{
+			name:      "auto options",
+			method:    http.MethodOptions,
+			path:      "/user/123",
+			wantCode:  http.StatusNoContent,
+			wantAllow: "GET, HEAD, OPTIONS, POST",
+		},
+		{
+			name:     "auto options not found",
+			method:   http.MethodOptions,
+			path:     "/order",
+			wantCode: http.StatusNotFound,
+			wantResp: "NOT FOUND",
+		},
+		{
+			name:      "auto options disabled",
+			opts:      []HTTPServerOption{ServerWithAutoOptions(false)},
+			method:    http.MethodOptions,
+			path:      "/user/123",
+			wantCode:  http.StatusMethodNotAllowed,
+			wantAllow: "GET, HEAD, POST",
+			wantResp:  "METHOD NOT ALLOWED",
+		},
+		{
+			name:       "auto head",
+			method:     http.MethodHead,
+			path:       "/user/123",
+			wantCode:   http.StatusOK,
+			wantLength: "5",
+		},
+		{
+			name:       "auto head disabled",
+			opts:       []HTTPServerOption{ServerWithAutoHead(false)},
+			method:     http.MethodHead,
+			path:       "/user/123",
+			wantCode:   http.StatusMethodNotAllowed,
+			wantAllow:  "GET, OPTIONS, POST",
+			wantLength: "18",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHttpServer(tc.opts...)
+			h.Get("/user/:id", handler)
+			h.Post("/user/:id", handler)
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantAllow, recorder.Header().Get("Allow"))
+			assert.Equal(t, tc.wantLength, recorder.Header().Get("Content-Length"))
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+		})
+	}
+}
+
+func TestHTTPServer_CompiledChain(t *testing.T) {
+	var builds int
+	var mdsBuilder = func(i byte) Middleware {
+		return func(next HandleFunc) HandleFunc {
+			builds++
+			return func(ctx *Context) {
+				ctx.RespData = append(ctx.RespData, i)
+				next(ctx)
+			}
+		}
+	}
+	h := NewHttpServer(ServerWithMiddleware(mdsBuilder('g')))
+	api := h.Group("/api", mdsBuilder('a'))
+	api.Get("/user", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+	}, mdsBuilder('u'))
+
+	serve := func(path string) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, req)
+		return recorder.Body.String()
+	}
+	assert.Equal(t, "gau", serve("/api/user"))
+	built := builds
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "gau", serve("/api/user"))
+	}
+	// 请求的时候不会重新组装 middleware
+	assert.Equal(t, built, builds)
+
+	// 注册新的路由之后重新组装
+	api.Get("/order", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+	})
+	assert.Equal(t, "ga", serve("/api/order"))
+	assert.Equal(t, "gau", serve("/api/user"))
+}
+
+func TestHTTPServer_ContextReuse(t *testing.T) {
+	h := NewHttpServer()
+	h.Get("/user/:id", func(ctx *Context) {
+		id, _ := ctx.PathValue("id").AsString()
+		name, _ := ctx.QueryValue("name").AsString()
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = append(ctx.RespData, fmt.Sprintf("%s:%s:%d", id, name, len(ctx.PathParams))...)
+	})
+	h.Get("/static", func(ctx *Context) {
+		name, _ := ctx.QueryValue("name").AsString()
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = append(ctx.RespData, fmt.Sprintf("%s:%d:%s", name, len(ctx.PathParams), ctx.MatchedRoute)...)
+	})
+	// 上一个请求留下的参数、查询参数和响应不能出现在下一个请求里面
+	testCases := []struct {
+		path     string
+		wantResp string
+	}{
+		{path: "/user/1?name=tom", wantResp: "1:tom:1"},
+		{path: "/static", wantResp: ":0:/static"},
+		{path: "/user/2", wantResp: "2::1"},
+		{path: "/static?name=jerry", wantResp: "jerry:0:/static"},
+	}
+	for i := 0; i < 3; i++ {
+		for _, tc := range testCases {
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+		}
+	}
+}
+
+// BenchmarkHTTPServer_ServeHTTP pooled 是 ServeHTTP 复用 Context 的情况，unpooled 每个请求都创建新的 Context
+func BenchmarkHTTPServer_ServeHTTP(b *testing.B) {
+	h := NewHttpServer()
+	h.Get("/user/:id/order/:oid", func(ctx *Context) {
+		_, _ = ctx.PathValue("id").AsString()
+		_, _ = ctx.QueryValue("page").AsInt()
+		ctx.RespStatusCode = http.StatusOK
+	})
+	req := httptest.NewRequest(http.MethodGet, "/user/123/order/456?page=1&size=20", nil)
+	w := &benchWriter{header: http.Header{}}
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			h.ServeHTTP(w, req)
+		}
+	})
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			h.root(&Context{Req: req, Resp: w})
+		}
+	})
+}
+
+type benchWriter struct {
+	header http.Header
+}
+
+func (w *benchWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *benchWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (w *benchWriter) WriteHeader(statusCode int) {}
@@ -0,0 +1,357 @@
+package web
+
+import (
+	"strings"
+)
+
+// routeTable 路由表的快照，发布之后就不会再修改
+type routeTable struct {
+	trees map[string]*node
+	names map[string]*namedRoute
+	// fallbacks 分组自定义的 404 和 405 处理，按照前缀长度从长到短排列
+	fallbacks []*fallback
+}
+
+var emptyTable = &routeTable{}
+
+func (r *router) load() *routeTable {
+	if t, ok := r.table.Load().(*routeTable); ok {
+		return t
+	}
+	return emptyTable
+}
+
+// routeTx 一次对路由表的修改，只有被修改的节点和它们的祖先才会被复制，其余的节点和旧的路由表共享
+type routeTx struct {
+	table *routeTable
+	// gen 这次修改的编号，gen 相同的节点是这次修改复制或者新建的，可以直接修改
+	gen uint64
+	// namesCopied names 已经复制过，可以直接修改
+	namesCopied bool
+}
+
+// update 在当前路由表的副本上执行 fn，执行完之后整体替换
+// 正在处理的请求继续使用旧的路由表，fn panic 的时候路由表保持不变
+func (r *router) update(fn func(tx *routeTx)) {
+	if err := r.tryUpdate(fn); err != nil {
+		panic(err.value)
+	}
+}
+
+// tryUpdate 和 update 一样，只是把 fn 的 panic 转换成 RouteError 返回
+func (r *router) tryUpdate(fn func(tx *routeTx)) (err *RouteError) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = toRouteError(v)
+		}
+	}()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old := r.load()
+	r.gen++
+	tx := &routeTx{
+		table: &routeTable{
+			trees:     make(map[string]*node, len(old.trees)+1),
+			names:     old.names,
+			fallbacks: old.fallbacks,
+		},
+		gen: r.gen,
+	}
+	for method, root := range old.trees {
+		tx.table.trees[method] = root
+	}
+	fn(tx)
+	for _, root := range tx.table.trees {
+		if root.gen == tx.gen {
+			tx.prune(root)
+			root.buildChain(nil, tx.gen)
+		}
+	}
+	r.table.Store(tx.table)
+	return nil
+}
+
+// tree 返回可以修改的路由树的根节点，第一次访问的时候复制一份
+func (tx *routeTx) tree(method string) *node {
+	root, ok := tx.table.trees[method]
+	switch {
+	case !ok:
+		root = &node{
+			path:     "/",
+			nodeType: FULLPATH,
+			gen:      tx.gen,
+		}
+	case root.gen != tx.gen:
+		root = root.copy(tx.gen)
+	default:
+		return root
+	}
+	tx.table.trees[method] = root
+	return root
+}
+
+// names 返回可以修改的命名路由，第一次访问的时候复制一份
+func (tx *routeTx) names() map[string]*namedRoute {
+	if tx.namesCopied {
+		return tx.table.names
+	}
+	names := make(map[string]*namedRoute, len(tx.table.names)+1)
+	for name, nr := range tx.table.names {
+		names[name] = nr
+	}
+	tx.table.names = names
+	tx.namesCopied = true
+	return names
+}
+
+// own 返回 parent 下面可以修改的 child，parent 必须已经是可以修改的
+// child 还和旧的路由表共享的时候复制一份，替换掉 parent 上的引用
+// 路由表里面的节点 gen 都不为 0，gen 为 0 的是这次修改里面刚刚创建的节点，不需要复制
+func (tx *routeTx) own(parent *node, child *node) *node {
+	if child.gen == tx.gen {
+		return child
+	}
+	if child.gen == 0 {
+		child.gen = tx.gen
+		return child
+	}
+	res := child.copy(tx.gen)
+	switch child {
+	case parent.reChild:
+		parent.reChild = res
+	case parent.pathChild:
+		parent.pathChild = res
+	case parent.starChild:
+		parent.starChild = res
+	default:
+		for i, c := range parent.children {
+			if c == child {
+				parent.children[i] = res
+			}
+		}
+		for i, c := range parent.mixChildren {
+			if c == child {
+				parent.mixChildren[i] = res
+			}
+		}
+	}
+	return res
+}
+
+// ownSubtree 复制 n 下面所有的节点，n 上的 middleware 变化之后整棵子树的 chain 都要重新计算
+func (tx *routeTx) ownSubtree(n *node) {
+	for i, child := range n.children {
+		n.children[i] = child.clone(tx.gen)
+	}
+	for i, child := range n.mixChildren {
+		n.mixChildren[i] = child.clone(tx.gen)
+	}
+	n.reChild = n.reChild.clone(tx.gen)
+	n.pathChild = n.pathChild.clone(tx.gen)
+	n.starChild = n.starChild.clone(tx.gen)
+}
+
+// RemoveRoute 删除路由，包括这个路径上带条件的路由，返回路由是否存在
+// 路由上挂着的分组 middleware 不会被删除
+func (r *router) RemoveRoute(method string, path string) bool {
+	removed := false
+	r.update(func(tx *routeTx) {
+		parts := patternParts(path)
+		if _, ok := tx.table.trees[method]; !ok {
+			return
+		}
+		n := tx.tree(method)
+		for _, p := range parts {
+			if n = tx.staticChild(n, p.static); n == nil {
+				return
+			}
+			if p.dynamic == "" {
+				continue
+			}
+			child := n.dynamicChild(p.dynamic)
+			if child == nil {
+				return
+			}
+			n = tx.own(n, child)
+		}
+		removed = n.hasHandler()
+		if len(n.routeMds) > 0 {
+			tx.ownSubtree(n)
+		}
+		n.handler, n.routeMds, n.conds, n.route, n.loc = nil, nil, nil, "", ""
+		if removed && !tx.hasRoute(path) {
+			for name, nr := range tx.table.names {
+				if nr.pattern == path {
+					delete(tx.names(), name)
+				}
+			}
+		}
+	})
+	return removed
+}
+
+// ReplaceRoute 替换路由的 handler 和 middleware，路由不存在的时候相当于 AddRoute
+// 带条件的路由保持不变，删除路由使用 RemoveRoute
+func (r *router) ReplaceRoute(method string, path string, handleFunc HandleFunc, mds ...Middleware) {
+	if handleFunc == nil {
+		panic("web: handler 不能为 nil")
+	}
+	r.update(func(tx *routeTx) {
+		n := tx.childOrCreatePath(method, path)
+		if len(n.routeMds) > 0 || len(mds) > 0 {
+			tx.ownSubtree(n)
+		}
+		n.handler = handleFunc
+		n.routeMds = mds
+		if n.route == "" {
+			n.loc = callerLocation()
+		}
+		n.route = path
+	})
+}
+
+// hasRoute 任意一个 method 下还有 path 对应的路由
+func (tx *routeTx) hasRoute(path string) bool {
+	found := false
+	for _, root := range tx.table.trees {
+		root.walk(func(n *node) {
+			found = found || n.route == path
+		})
+	}
+	return found
+}
+
+// staticChild 沿着静态前缀往下找，path 必须刚好落在节点的边界上，经过的节点都会变成可以修改的
+func (tx *routeTx) staticChild(n *node, path string) *node {
+	for path != "" {
+		idx := strings.IndexByte(n.indices, path[0])
+		if idx < 0 || !strings.HasPrefix(path, n.children[idx].path) {
+			return nil
+		}
+		n = tx.own(n, n.children[idx])
+		path = path[len(n.path):]
+	}
+	return n
+}
+
+// dynamicChild 找到和路由里面的段 s 完全相同的动态子节点
+func (n *node) dynamicChild(s string) *node {
+	if _, ok := parseMixedSegment(s); ok {
+		for _, child := range n.mixChildren {
+			if child.path == s {
+				return child
+			}
+		}
+		return nil
+	}
+	if s[0] == '*' {
+		if n.starChild != nil && n.starChild.path == s {
+			return n.starChild
+		}
+		return nil
+	}
+	name, expr, typ := n.parseParam(s)
+	if expr == "" && typ == "" {
+		if n.pathChild != nil && n.pathChild.path == name {
+			return n.pathChild
+		}
+		return nil
+	}
+	if c := n.reChild; c != nil && c.path == name {
+		if (typ != "" && c.constraint != nil && c.constraint.name == typ) ||
+			(typ == "" && c.constraint == nil && c.reg.String() == expr) {
+			return c
+		}
+	}
+	return nil
+}
+
+// copy 浅复制节点，子节点仍然和旧的路由表共享，修改子节点之前要先通过 routeTx.own 复制
+func (n *node) copy(gen uint64) *node {
+	res := *n
+	res.gen = gen
+	if len(n.children) > 0 {
+		res.children = append([]*node(nil), n.children...)
+	}
+	if len(n.mixChildren) > 0 {
+		res.mixChildren = append([]*node(nil), n.mixChildren...)
+	}
+	if len(n.conds) > 0 {
+		res.conds = make([]*condRoute, len(n.conds))
+		for i, c := range n.conds {
+			cc := *c
+			res.conds[i] = &cc
+		}
+	}
+	// 限制容量，保证之后 append 的时候不会修改旧路由表上的切片
+	res.mds = n.mds[:len(n.mds):len(n.mds)]
+	return &res
+}
+
+// clone 复制整棵子树
+func (n *node) clone(gen uint64) *node {
+	if n == nil {
+		return nil
+	}
+	res := n.copy(gen)
+	for i, child := range res.children {
+		res.children[i] = child.clone(gen)
+	}
+	for i, child := range res.mixChildren {
+		res.mixChildren[i] = child.clone(gen)
+	}
+	res.reChild = n.reChild.clone(gen)
+	res.pathChild = n.pathChild.clone(gen)
+	res.starChild = n.starChild.clone(gen)
+	return res
+}
+
+// empty 节点上没有路由、middleware 和子节点
+func (n *node) empty() bool {
+	return !n.hasHandler() && len(n.mds) == 0 && len(n.children) == 0 && !n.hasDynamic()
+}
+
+// prune 删除路由之后清理空节点，并且把只有一个静态子节点的静态节点合并回去
+// 没有被这次修改复制过的子树和修改之前一样，不需要再检查
+func (tx *routeTx) prune(n *node) {
+	children := n.children[:0]
+	indices := make([]byte, 0, len(n.indices))
+	for _, child := range n.children {
+		if child.gen == tx.gen {
+			tx.prune(child)
+			if child.empty() {
+				continue
+			}
+			if child.nodeType == FULLPATH && !child.hasHandler() && len(child.mds) == 0 &&
+				!child.hasDynamic() && len(child.children) == 1 {
+				grand := tx.own(child, child.children[0])
+				grand.path = child.path + grand.path
+				child = grand
+			}
+		}
+		children = append(children, child)
+		indices = append(indices, child.path[0])
+	}
+	n.children = children
+	n.indices = string(indices)
+	mixChildren := n.mixChildren[:0]
+	for _, child := range n.mixChildren {
+		if child.gen == tx.gen {
+			tx.prune(child)
+			if child.empty() {
+				continue
+			}
+		}
+		mixChildren = append(mixChildren, child)
+	}
+	n.mixChildren = mixChildren
+	for _, child := range []**node{&n.reChild, &n.pathChild, &n.starChild} {
+		if *child == nil || (*child).gen != tx.gen {
+			continue
+		}
+		tx.prune(*child)
+		if (*child).empty() {
+			*child = nil
+		}
+	}
+}
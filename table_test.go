@@ -0,0 +1,177 @@
+package web
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestRouter_RemoveAndReplace(t *testing.T) {
+	var handler = func(name string) HandleFunc {
+		return func(ctx *Context) {
+			ctx.RespStatusCode = http.StatusOK
+			ctx.RespData = append(ctx.RespData, name...)
+		}
+	}
+	var mdsBuilder = func(i byte) Middleware {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				ctx.RespData = append(ctx.RespData, i)
+				next(ctx)
+			}
+		}
+	}
+	serve := func(h *HTTPServer, method string, path string) (int, string) {
+		req := httptest.NewRequest(method, path, nil)
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, req)
+		return recorder.Code, recorder.Body.String()
+	}
+
+	h := NewHttpServer()
+	api := h.Group("/api", mdsBuilder('a'))
+	api.AddNamedRoute("user", http.MethodGet, "/user/:id", handler("user"), mdsBuilder('u'))
+	api.Get("/users", handler("users"))
+	h.Get("/api2", handler("api2"))
+
+	code, body := serve(h, http.MethodGet, "/api/user/1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "auuser", body)
+
+	api.ReplaceRoute(http.MethodGet, "/user/:id", handler("user v2"))
+	_, body = serve(h, http.MethodGet, "/api/user/1")
+	assert.Equal(t, "auser v2", body)
+
+	// handler 不能为 nil，删除路由要用 RemoveRoute
+	assert.PanicsWithValue(t, "web: handler 不能为 nil", func() {
+		api.ReplaceRoute(http.MethodGet, "/user/:id", nil)
+	})
+	_, body = serve(h, http.MethodGet, "/api/user/1")
+	assert.Equal(t, "auser v2", body)
+
+	assert.True(t, api.RemoveRoute(http.MethodGet, "/user/:id"))
+	assert.False(t, api.RemoveRoute(http.MethodGet, "/user/:id"))
+	assert.False(t, h.RemoveRoute(http.MethodGet, "/api/user/:uid"))
+	assert.False(t, h.RemoveRoute(http.MethodPost, "/api/users"))
+	code, _ = serve(h, http.MethodGet, "/api/user/1")
+	assert.Equal(t, http.StatusNotFound, code)
+	_, err := h.URLFor("user", "id", 1)
+	assert.EqualError(t, err, "web: 找不到名字为 [user] 的路由")
+
+	// 删除之后空的节点会被清理，只剩一个子节点的静态节点会被合并
+	assert.True(t, h.RemoveRoute(http.MethodGet, "/api/users"))
+	_, body = serve(h, http.MethodGet, "/api2")
+	assert.Equal(t, "api2", body)
+	root := h.load().trees[http.MethodGet]
+	require.Len(t, root.children, 1)
+	assert.Equal(t, "api", root.children[0].path)
+	require.Len(t, root.children[0].children, 1)
+	assert.Equal(t, "2", root.children[0].children[0].path)
+
+	// 分组的 middleware 还在，重新注册之后仍然生效
+	api.Get("/users", handler("users"))
+	_, body = serve(h, http.MethodGet, "/api/users")
+	assert.Equal(t, "ausers", body)
+
+	// 注册失败的时候路由表保持不变
+	before := h.load()
+	assert.Panics(t, func() {
+		h.Get("/api/users", handler("users"))
+	})
+	assert.Same(t, before, h.load())
+}
+
+func TestRouter_ConcurrentUpdate(t *testing.T) {
+	h := NewHttpServer()
+	h.Get("/ping", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+				recorder := httptest.NewRecorder()
+				h.ServeHTTP(recorder, req)
+				assert.Equal(t, http.StatusOK, recorder.Code)
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		path := "/feature/" + strconv.Itoa(i)
+		h.Get(path, func(ctx *Context) {})
+		if i%2 == 0 {
+			h.RemoveRoute(http.MethodGet, path)
+		}
+	}
+	wg.Wait()
+	assert.Len(t, h.Routes(), 26)
+}
+
+func TestRouter_SharedSnapshot(t *testing.T) {
+	var mdsBuilder = func(i byte) Middleware {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				ctx.RespData = append(ctx.RespData, i)
+				next(ctx)
+			}
+		}
+	}
+	serve := func(table *routeTable, path string) string {
+		ps := getParams()
+		defer putParams(ps)
+		n, ok := table.trees[http.MethodGet].match(path[1:], ps)
+		if !ok {
+			return "404"
+		}
+		ctx := &Context{}
+		n.compiled(ctx)
+		return string(ctx.RespData)
+	}
+	h := NewHttpServer()
+	api := h.Group("/api", mdsBuilder('a'))
+	api.Get("/users", func(ctx *Context) {
+		ctx.RespData = append(ctx.RespData, "users"...)
+	})
+	api.Get("/user/:id", func(ctx *Context) {
+		ctx.RespData = append(ctx.RespData, "user"...)
+	})
+	h.Get("/ping", func(ctx *Context) {
+		ctx.RespData = append(ctx.RespData, "pong"...)
+	})
+	before := h.load()
+
+	// 只复制修改的路径，没有修改的子树和旧的路由表共享
+	h.Get("/api/user/:id/orders", func(ctx *Context) {})
+	after := h.load()
+	assert.Same(t, before.trees[http.MethodGet].children[0].children[0].children[0],
+		after.trees[http.MethodGet].children[0].children[0].children[0])
+
+	// 修改 middleware、替换和删除路由都不会影响旧的路由表
+	h.Group("/api", mdsBuilder('b')).Get("/orders", func(ctx *Context) {})
+	h.ReplaceRoute(http.MethodGet, "/api/users", func(ctx *Context) {
+		ctx.RespData = append(ctx.RespData, "users v2"...)
+	})
+	h.RemoveRoute(http.MethodGet, "/ping")
+	assert.Equal(t, "ausers", serve(before, "/api/users"))
+	assert.Equal(t, "auser", serve(before, "/api/user/1"))
+	assert.Equal(t, "pong", serve(before, "/ping"))
+	assert.Equal(t, "abusers v2", serve(h.load(), "/api/users"))
+	assert.Equal(t, "abuser", serve(h.load(), "/api/user/1"))
+	assert.Equal(t, "404", serve(h.load(), "/ping"))
+}
+
+// BenchmarkRouter_AddRoute 每次注册只复制修改的路径，注册的耗时不会随着路由数量增长
+func BenchmarkRouter_AddRoute(b *testing.B) {
+	r := NewRouter()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.AddRoute(http.MethodGet, "/api/res"+strconv.Itoa(i)+"/:id", func(ctx *Context) {})
+	}
+}
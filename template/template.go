@@ -10,6 +10,12 @@ type TemplateEngine interface {
 	Render(ctx context.Context, tplName string, data any) ([]byte, error)
 }
 
+// FuncsRegister 支持注册模板函数的模板引擎
+// go 的模板要求函数在解析之前就已经声明，解析之后注册只能替换同名函数的实现
+type FuncsRegister interface {
+	RegisterFuncs(funcs map[string]any)
+}
+
 type goTemplateEngine struct {
 	temp *template.Template
 }
@@ -22,3 +28,7 @@ func (g *goTemplateEngine) Render(ctx context.Context, tplName string, data any)
 	err := g.temp.ExecuteTemplate(bs, tplName, data)
 	return bs.Bytes(), err
 }
+
+func (g *goTemplateEngine) RegisterFuncs(funcs map[string]any) {
+	g.temp.Funcs(funcs)
+}
@@ -0,0 +1,148 @@
+package web
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// ServerWithH2C 开启 h2c，也就是明文的 HTTP/2
+// 一般用于内部负载均衡已经卸载了 TLS 的场景，只对 Start 生效
+func ServerWithH2C() HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.h2c = true
+	}
+}
+
+// ServerWithCertReloadInterval 设置检查证书文件是否变更的最小间隔，只对 StartTLS 生效
+// interval <= 0 表示每次握手都检查
+func ServerWithCertReloadInterval(interval time.Duration) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.certReloadInterval = interval
+	}
+}
+
+// StartTLS 使用证书文件启动 HTTPS 服务，支持 HTTP/2
+// 证书文件在磁盘上被替换之后，新的连接会自动使用新证书，不需要重启
+func (h *HTTPServer) StartTLS(addr string, certFile string, keyFile string) error {
+	reloader, err := newCertReloader(certFile, keyFile, h.certReloadInterval, h.log)
+	if err != nil {
+		return err
+	}
+	return h.StartTLSWithConfig(addr, &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+	})
+}
+
+// StartTLSWithConfig 使用自定义的 tls.Config 启动 HTTPS 服务
+func (h *HTTPServer) StartTLSWithConfig(addr string, cfg *tls.Config) error {
+	if cfg == nil {
+		return fmt.Errorf("web: tls.Config 不能为 nil")
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return h.serveTLS(l, cfg)
+}
+
+// RunTLS 跟 Run 一样监听退出信号，区别是启动的是 HTTPS 服务
+func (h *HTTPServer) RunTLS(addr string, certFile string, keyFile string, signals ...os.Signal) error {
+	return h.runUntilSignal(func() error {
+		return h.StartTLS(addr, certFile, keyFile)
+	}, signals)
+}
+
+// certReloader 在握手的时候检查证书文件的修改时间，变更了就重新加载
+type certReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	log      func(msg string, args ...any)
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func newCertReloader(certFile string, keyFile string, interval time.Duration,
+	log func(msg string, args ...any)) (*certReloader, error) {
+	res := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+		log:      log,
+	}
+	modTime, err := res.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err = res.load(modTime); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.maybeReload()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+func (c *certReloader) maybeReload() {
+	c.mu.RLock()
+	skip := c.interval > 0 && time.Since(c.lastCheck) < c.interval
+	c.mu.RUnlock()
+	if skip {
+		return
+	}
+	c.mu.Lock()
+	c.lastCheck = time.Now()
+	modTime := c.modTime
+	c.mu.Unlock()
+
+	latest, err := c.latestModTime()
+	if err != nil {
+		c.log("web: 检查证书文件失败 %v\n", err)
+		return
+	}
+	if !latest.After(modTime) {
+		return
+	}
+	// 加载失败的时候继续使用旧证书，比如证书和私钥只替换了一半
+	if err = c.load(latest); err != nil {
+		c.log("web: 重新加载证书失败 %v\n", err)
+	}
+}
+
+func (c *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("web: 加载证书失败 %w", err)
+	}
+	c.mu.Lock()
+	c.cert = &cert
+	c.modTime = modTime
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *certReloader) latestModTime() (time.Time, error) {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if keyInfo.ModTime().After(certInfo.ModTime()) {
+		return keyInfo.ModTime(), nil
+	}
+	return certInfo.ModTime(), nil
+}
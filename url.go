@@ -0,0 +1,209 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// namedRoute 命名路由，保存反向生成 URL 需要的信息
+type namedRoute struct {
+	pattern string
+	segs    []urlSeg
+}
+
+type urlSeg struct {
+	// 静态段
+	literal string
+	// 参数名，通配符为 *
+	param string
+	// 正则参数的正则表达式
+	reg *regexp.Regexp
+	// 类型参数的约束
+	constraint *paramConstraint
+	// 参数嵌在段中间的段，按照顺序填充每个参数
+	mixed *mixedSeg
+	star  bool
+}
+
+// AddNamedRoute 注册路由并起一个名字，之后可以通过 URLFor 反向生成 URL
+// 同一个名字可以在不同的 method 下注册同一个路径
+func (r *router) AddNamedRoute(name string, method string, path string, handleFunc HandleFunc, mds ...Middleware) {
+	r.addNamedRoute(name, method, path, nil, handleFunc, mds...)
+}
+
+func (r *router) addNamedRoute(name string, method string, path string, preds []Predicate, handleFunc HandleFunc, mds ...Middleware) {
+	if name == "" {
+		panic("web: 路由名字不能为空字符串")
+	}
+	r.update(func(tx *routeTx) {
+		if nr, ok := tx.table.names[name]; ok && nr.pattern != path {
+			reason := fmt.Sprintf("web: 路由名字冲突，[%s] 已经用于 %s，新注册 %s", name, nr.pattern, path)
+			panic(&RouteError{Kind: RouteConflict, Conflict: nr.pattern, Reason: reason, value: reason})
+		}
+		tx.addRoute(method, path, preds, handleFunc, mds...)
+		tx.names()[name] = newNamedRoute(tx.tree(method), path)
+	})
+}
+
+// newNamedRoute 根据路由生成反向生成 URL 需要的信息，root 只用来解析参数
+func newNamedRoute(root *node, path string) *namedRoute {
+	res := &namedRoute{pattern: path}
+	if path == "/" {
+		return res
+	}
+	for _, s := range strings.Split(path[1:], "/") {
+		if parts, ok := parseMixedSegment(s); ok {
+			res.segs = append(res.segs, urlSeg{mixed: newMixedSeg(parts)})
+			continue
+		}
+		switch s[0] {
+		case ':':
+			name, expr, typ := root.parseParam(s)
+			seg := urlSeg{param: name}
+			if expr != "" {
+				// 注册路由的时候已经校验过正则
+				seg.reg = regexp.MustCompile(expr)
+			}
+			if typ != "" {
+				seg.constraint, _ = findParamConstraint(typ)
+			}
+			res.segs = append(res.segs, seg)
+		case '*':
+			param := s[1:]
+			if param == "" {
+				param = "*"
+			}
+			res.segs = append(res.segs, urlSeg{param: param, star: true})
+		default:
+			res.segs = append(res.segs, urlSeg{literal: s})
+		}
+	}
+	return res
+}
+
+// TemplateFuncs 返回路由相关的模板函数，在解析模板之前通过 Funcs 注册，模板里面才能引用
+// 例如 {{ urlFor "user" "id" .ID }}
+// 使用 ServerWithTemplateEngine 之后，函数的实现会绑定到对应的 HTTPServer 上
+func TemplateFuncs() map[string]any {
+	return map[string]any{
+		"urlFor": func(name string, params ...any) (string, error) {
+			return "", errors.New("web: urlFor 还没有绑定到 HTTPServer 上")
+		},
+	}
+}
+
+func (h *HTTPServer) templateFuncs() map[string]any {
+	return map[string]any{
+		"urlFor": h.URLFor,
+	}
+}
+
+// URLFor 根据路由名字生成 URL，params 是成对的参数名和参数值
+// 例如 URLFor("user", "id", 123)，匿名通配符使用 * 作为参数名，命名通配符使用自己的名字
+// 没有在路径里面用到的参数会作为查询参数拼接在后面
+func (r *router) URLFor(name string, params ...any) (string, error) {
+	nr, ok := r.load().names[name]
+	if !ok {
+		return "", fmt.Errorf("web: 找不到名字为 [%s] 的路由", name)
+	}
+	if len(params)%2 != 0 {
+		return "", errors.New("web: URLFor 的参数必须是成对的参数名和参数值")
+	}
+	values := make(map[string]string, len(params)/2)
+	keys := make([]string, 0, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		key, ok := params[i].(string)
+		if !ok {
+			return "", fmt.Errorf("web: URLFor 的参数名必须是字符串，实际是 %T", params[i])
+		}
+		if _, ok = values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = fmt.Sprint(params[i+1])
+	}
+	if len(nr.segs) == 0 {
+		return nr.pattern + buildQuery(keys, values, nil), nil
+	}
+	used := make(map[string]bool, len(nr.segs))
+	var sb strings.Builder
+	for _, seg := range nr.segs {
+		sb.WriteByte('/')
+		if seg.mixed != nil {
+			if err := seg.mixed.build(&sb, nr.pattern, values, used); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if seg.param == "" {
+			sb.WriteString(seg.literal)
+			continue
+		}
+		val, ok := values[seg.param]
+		if !ok || val == "" {
+			return "", fmt.Errorf("web: 路由 [%s] 缺少参数 [%s]", nr.pattern, seg.param)
+		}
+		used[seg.param] = true
+		if c := seg.constraint; c != nil && !c.match(val) {
+			return "", fmt.Errorf("web: 路由 [%s] 的参数 [%s] 不是合法的 %s，实际值 %s",
+				nr.pattern, seg.param, c.name, val)
+		}
+		if seg.reg != nil && !seg.reg.MatchString(val) {
+			return "", fmt.Errorf("web: 路由 [%s] 的参数 [%s] 不匹配正则 %s，实际值 %s",
+				nr.pattern, seg.param, seg.reg.String(), val)
+		}
+		if seg.star {
+			// 通配符可以匹配多段，保留其中的 /
+			parts := strings.Split(strings.TrimPrefix(val, "/"), "/")
+			for i, p := range parts {
+				parts[i] = url.PathEscape(p)
+			}
+			sb.WriteString(strings.Join(parts, "/"))
+			continue
+		}
+		sb.WriteString(url.PathEscape(val))
+	}
+	return sb.String() + buildQuery(keys, values, used), nil
+}
+
+// build 按照顺序拼接静态部分和参数值，参数值需要满足类型约束
+func (m *mixedSeg) build(sb *strings.Builder, pattern string, values map[string]string, used map[string]bool) error {
+	for _, p := range m.parts {
+		if p.param == "" {
+			sb.WriteString(p.literal)
+			continue
+		}
+		val, ok := values[p.param]
+		if !ok || val == "" {
+			return fmt.Errorf("web: 路由 [%s] 缺少参数 [%s]", pattern, p.param)
+		}
+		if p.constraint != nil && !p.constraint.match(val) {
+			return fmt.Errorf("web: 路由 [%s] 的参数 [%s] 不是合法的 %s，实际值 %s",
+				pattern, p.param, p.constraint.name, val)
+		}
+		used[p.param] = true
+		sb.WriteString(url.PathEscape(val))
+	}
+	return nil
+}
+
+// buildQuery 没有用到的参数按照传入的顺序拼接成查询参数
+func buildQuery(keys []string, values map[string]string, used map[string]bool) string {
+	var sb strings.Builder
+	for _, key := range keys {
+		if used[key] {
+			continue
+		}
+		if sb.Len() == 0 {
+			sb.WriteByte('?')
+		} else {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(url.QueryEscape(key))
+		sb.WriteByte('=')
+		sb.WriteString(url.QueryEscape(values[key]))
+	}
+	return sb.String()
+}
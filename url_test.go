@@ -0,0 +1,136 @@
+package web
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	temp "html/template"
+	"net/http"
+	"net/http/httptest"
+	"routing/template"
+	"testing"
+)
+
+func TestRouter_URLFor(t *testing.T) {
+	r := NewRouter()
+	r.AddNamedRoute("home", http.MethodGet, "/", mockHandler)
+	r.AddNamedRoute("user", http.MethodGet, "/user/:id", mockHandler)
+	r.AddNamedRoute("user", http.MethodPost, "/user/:id", mockHandler)
+	r.AddNamedRoute("order", http.MethodGet, "/order/:id(^[0-9]+$)/detail", mockHandler)
+	r.AddNamedRoute("static", http.MethodGet, "/static/*", mockHandler)
+	r.AddNamedRoute("assets", http.MethodGet, "/assets/*filepath", mockHandler)
+	r.AddNamedRoute("file", http.MethodGet, "/v:version<int>/files/:name.:ext", mockHandler)
+
+	testCases := []struct {
+		name    string
+		route   string
+		params  []any
+		wantURL string
+		wantErr string
+	}{
+		{
+			name:    "root",
+			route:   "home",
+			wantURL: "/",
+		},
+		{
+			name:    "param",
+			route:   "user",
+			params:  []any{"id", 123},
+			wantURL: "/user/123",
+		},
+		{
+			name:    "escape",
+			route:   "user",
+			params:  []any{"id", "a b/c"},
+			wantURL: "/user/a%20b%2Fc",
+		},
+		{
+			name:    "extra params as query",
+			route:   "user",
+			params:  []any{"id", 123, "page", 2, "q", "a&b"},
+			wantURL: "/user/123?page=2&q=a%26b",
+		},
+		{
+			name:    "regex",
+			route:   "order",
+			params:  []any{"id", 42},
+			wantURL: "/order/42/detail",
+		},
+		{
+			name:    "regex not match",
+			route:   "order",
+			params:  []any{"id", "abc"},
+			wantErr: "web: 路由 [/order/:id(^[0-9]+$)/detail] 的参数 [id] 不匹配正则 ^[0-9]+$，实际值 abc",
+		},
+		{
+			name:    "star",
+			route:   "static",
+			params:  []any{"*", "css/a b.css"},
+			wantURL: "/static/css/a%20b.css",
+		},
+		{
+			name:    "named star",
+			route:   "assets",
+			params:  []any{"filepath", "js/app.js"},
+			wantURL: "/assets/js/app.js",
+		},
+		{
+			name:    "mixed",
+			route:   "file",
+			params:  []any{"version", 2, "name", "a b", "ext", "txt"},
+			wantURL: "/v2/files/a%20b.txt",
+		},
+		{
+			name:    "mixed invalid type",
+			route:   "file",
+			params:  []any{"version", "x", "name", "a", "ext", "txt"},
+			wantErr: "web: 路由 [/v:version<int>/files/:name.:ext] 的参数 [version] 不是合法的 int，实际值 x",
+		},
+		{
+			name:    "missing param",
+			route:   "user",
+			wantErr: "web: 路由 [/user/:id] 缺少参数 [id]",
+		},
+		{
+			name:    "odd params",
+			route:   "user",
+			params:  []any{"id"},
+			wantErr: "web: URLFor 的参数必须是成对的参数名和参数值",
+		},
+		{
+			name:    "unknown route",
+			route:   "unknown",
+			wantErr: "web: 找不到名字为 [unknown] 的路由",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := r.URLFor(tc.route, tc.params...)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantURL, res)
+		})
+	}
+
+	assert.PanicsWithValue(t, "web: 路由名字冲突，[user] 已经用于 /user/:id，新注册 /users/:id", func() {
+		r.AddNamedRoute("user", http.MethodGet, "/users/:id", mockHandler)
+	})
+}
+
+func TestHTTPServer_URLForTemplate(t *testing.T) {
+	tpl, err := temp.New("link").Funcs(TemplateFuncs()).Parse(`{{ urlFor "user" "id" . }}`)
+	require.NoError(t, err)
+	h := NewHttpServer(ServerWithTemplateEngine(template.NewGoTemplateEngine(tpl)))
+	h.Group("/api").AddNamedRoute("user", http.MethodGet, "/user/:id", func(ctx *Context) {
+		id, _ := ctx.PathValue("id").AsString()
+		_ = ctx.Render("link", id)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/api/user/123", nil)
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "/api/user/123", recorder.Body.String())
+}
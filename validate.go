@@ -0,0 +1,421 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Validator 校验绑定之后的结构体，设置了 Validator 的时候 Bind、BindBody 和 BindJson 成功之后会自动调用
+type Validator interface {
+	Validate(v any) error
+}
+
+// ValidatorFunc 函数形式的 Validator
+type ValidatorFunc func(v any) error
+
+func (f ValidatorFunc) Validate(v any) error {
+	return f(v)
+}
+
+// ServerWithValidator 设置绑定之后使用的 Validator，默认不做校验
+// 使用 struct 标签校验可以传入 NewTagValidator()，传入 nil 表示关闭校验
+func ServerWithValidator(v Validator) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.validator = v
+	}
+}
+
+// ServerWithValidationStatus RespProblem 渲染 ValidationErrors 时使用的状态码，默认是 422，也可以设置成 400
+func ServerWithValidationStatus(status int) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.validationStatus = status
+	}
+}
+
+// ValidationError 一个字段没有通过某一条规则
+type ValidationError struct {
+	// Field 字段在结构体里面的路径，例如 Address.City、Items[0].Name
+	Field string
+	// Rule 没有通过的规则，例如 min
+	Rule string
+	// Param 规则的参数，例如 min=3 里面的 3
+	Param   string
+	Value   any
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("web: 字段 %s 校验失败，%s", e.Field, e.Message)
+}
+
+// ValidationErrors 所有没有通过校验的字段
+type ValidationErrors []*ValidationError
+
+func (es ValidationErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// TagValidator 根据 validate 标签校验结构体，例如 validate:"required,min=3,max=10"
+// 支持的规则：
+//   - required 不能是零值，指针不能为 nil，字符串、切片和 map 不能为空
+//   - min=n、max=n 数字比较大小，字符串比较字符数，切片和 map 比较元素个数
+//   - len=n 字符串的字符数、切片和 map 的元素个数必须等于 n
+//   - oneof=a b c 必须是用空格分隔的值中的一个
+//   - email 必须是合法的邮箱地址
+//   - regex=^[a-z]+$ 字符串必须匹配正则表达式，正则里面可能有逗号，所以必须是最后一条规则
+//   - omitempty 零值的时候跳过其余的规则，不能和 required 一起使用
+//
+// 零值同样要通过其余的规则，例如 validate:"min=1" 的 0 不能通过校验，可选的字段需要加上 omitempty
+// 指针为 nil 的时候没有可以校验的值，除了 required 以外的规则都会跳过
+// 结构体字段，包括结构体指针和结构体切片，会递归校验
+type TagValidator struct {
+	plans sync.Map
+}
+
+// NewTagValidator 创建 TagValidator，解析之后的规则按照类型缓存
+func NewTagValidator() *TagValidator {
+	return &TagValidator{}
+}
+
+func (tv *TagValidator) Validate(v any) error {
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	if err := tv.validateStruct(val, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (tv *TagValidator) validateStruct(v reflect.Value, prefix string, errs *ValidationErrors) error {
+	plan, err := tv.planFor(v.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range plan {
+		fv := v.Field(f.index)
+		name := prefix + f.name
+		if e := validateRules(fv, name, f.rules); e != nil {
+			*errs = append(*errs, e)
+			continue
+		}
+		// 嵌入结构体的字段和外层的字段一样处理，不加前缀
+		if f.embedded {
+			name = strings.TrimSuffix(prefix, ".")
+		}
+		if err := tv.validateNested(fv, name, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateNested 递归校验结构体、结构体指针和结构体切片
+func (tv *TagValidator) validateNested(v reflect.Value, name string, errs *ValidationErrors) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	prefix := name
+	if prefix != "" {
+		prefix += "."
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return nil
+		}
+		return tv.validateStruct(v, prefix, errs)
+	case reflect.Slice, reflect.Array:
+		switch v.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Pointer, reflect.Slice, reflect.Array:
+		default:
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := tv.validateNested(v.Index(i), fmt.Sprintf("%s[%d]", name, i), errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateRules 返回第一条没有通过的规则
+func validateRules(v reflect.Value, name string, rules []rule) *ValidationError {
+	if len(rules) == 0 {
+		return nil
+	}
+	if v.IsZero() {
+		switch rules[0].name {
+		case "required":
+			return &ValidationError{Field: name, Rule: "required", Value: v.Interface(), Message: "不能为空"}
+		case "omitempty":
+			return nil
+		}
+	}
+	iv := v
+	for iv.Kind() == reflect.Pointer {
+		if iv.IsNil() {
+			return nil
+		}
+		iv = iv.Elem()
+	}
+	for _, r := range rules {
+		if r.check == nil {
+			continue
+		}
+		if msg, ok := r.check(iv); !ok {
+			return &ValidationError{Field: name, Rule: r.name, Param: r.param, Value: iv.Interface(), Message: msg}
+		}
+	}
+	return nil
+}
+
+type validateField struct {
+	index    int
+	name     string
+	rules    []rule
+	embedded bool
+}
+
+// rule 一条校验规则，check 返回不通过时的描述
+type rule struct {
+	name  string
+	param string
+	check func(v reflect.Value) (string, bool)
+}
+
+func (tv *TagValidator) planFor(t reflect.Type) ([]validateField, error) {
+	if p, ok := tv.plans.Load(t); ok {
+		return p.([]validateField), nil
+	}
+	var plan []validateField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		embedded := sf.Anonymous && sf.Type.Kind() == reflect.Struct
+		if !sf.IsExported() && !embedded {
+			continue
+		}
+		var rules []rule
+		// 没有导出的嵌入结构体只校验里面的字段
+		if sf.IsExported() {
+			var err error
+			if rules, err = parseRules(sf.Tag.Get("validate")); err != nil {
+				name := sf.Name
+				if t.Name() != "" {
+					name = t.Name() + "." + name
+				}
+				return nil, fmt.Errorf("web: 字段 %s 的校验规则错误 %w", name, err)
+			}
+		}
+		plan = append(plan, validateField{index: i, name: sf.Name, rules: rules, embedded: embedded})
+	}
+	tv.plans.Store(t, plan)
+	return plan, nil
+}
+
+// parseRules required 和 omitempty 总是放在第一个
+func parseRules(tag string) ([]rule, error) {
+	if tag == "" || tag == "-" {
+		return nil, nil
+	}
+	var res []rule
+	required, omitempty := false, false
+	for tag != "" {
+		var item string
+		if strings.HasPrefix(tag, "regex=") {
+			item, tag = tag, ""
+		} else {
+			item, tag, _ = strings.Cut(tag, ",")
+		}
+		name, param, _ := strings.Cut(strings.TrimSpace(item), "=")
+		switch name {
+		case "required":
+			required = true
+			continue
+		case "omitempty":
+			omitempty = true
+			continue
+		}
+		r, err := newRule(name, param)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	switch {
+	case required && omitempty:
+		return nil, errors.New("required 和 omitempty 不能同时使用")
+	case required:
+		res = append([]rule{{name: "required"}}, res...)
+	case omitempty:
+		res = append([]rule{{name: "omitempty"}}, res...)
+	}
+	return res, nil
+}
+
+func newRule(name string, param string) (rule, error) {
+	r := rule{name: name, param: param}
+	switch name {
+	case "min", "max", "len":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return r, fmt.Errorf("[%s=%s] 的参数必须是数字", name, param)
+		}
+		r.check = sizeCheck(name, param, n)
+	case "oneof":
+		options := strings.Fields(param)
+		r.check = func(v reflect.Value) (string, bool) {
+			s := fmt.Sprint(v.Interface())
+			for _, o := range options {
+				if s == o {
+					return "", true
+				}
+			}
+			return fmt.Sprintf("必须是 [%s] 中的一个", strings.Join(options, ", ")), false
+		}
+	case "email":
+		r.check = func(v reflect.Value) (string, bool) {
+			if v.Kind() == reflect.String {
+				if addr, err := mail.ParseAddress(v.String()); err == nil && addr.Address == v.String() {
+					return "", true
+				}
+			}
+			return "不是合法的邮箱地址", false
+		}
+	case "regex":
+		reg, err := regexp.Compile(param)
+		if err != nil {
+			return r, fmt.Errorf("[regex=%s] 正则表达式错误 %w", param, err)
+		}
+		r.check = func(v reflect.Value) (string, bool) {
+			if v.Kind() == reflect.String && reg.MatchString(v.String()) {
+				return "", true
+			}
+			return fmt.Sprintf("必须匹配 %s", param), false
+		}
+	default:
+		return r, fmt.Errorf("未知的校验规则 [%s]", name)
+	}
+	return r, nil
+}
+
+// sizeCheck 数字比较大小，字符串比较字符数，切片和 map 比较元素个数
+func sizeCheck(name string, param string, n float64) func(v reflect.Value) (string, bool) {
+	return func(v reflect.Value) (string, bool) {
+		var size float64
+		isLen := true
+		switch v.Kind() {
+		case reflect.String:
+			size = float64(utf8.RuneCountInString(v.String()))
+		case reflect.Slice, reflect.Map, reflect.Array:
+			size = float64(v.Len())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			size, isLen = float64(v.Int()), false
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			size, isLen = float64(v.Uint()), false
+		case reflect.Float32, reflect.Float64:
+			size, isLen = v.Float(), false
+		default:
+			return fmt.Sprintf("类型 %s 不支持 %s", v.Type(), name), false
+		}
+		what := "长度"
+		if !isLen {
+			what = "值"
+		}
+		switch {
+		case name == "min" && size < n:
+			return fmt.Sprintf("%s不能小于 %s", what, param), false
+		case name == "max" && size > n:
+			return fmt.Sprintf("%s不能大于 %s", what, param), false
+		case name == "len" && size != n:
+			return fmt.Sprintf("长度必须等于 %s", param), false
+		}
+		return "", true
+	}
+}
+
+// validate 使用 HTTPServer 上配置的 Validator 校验 dst
+func (c *Context) validate(dst any) error {
+	if c.validator == nil {
+		return nil
+	}
+	return c.validator.Validate(dst)
+}
+
+// Problem RFC 7807 格式的错误响应
+type Problem struct {
+	Type   string         `json:"type"`
+	Title  string         `json:"title"`
+	Status int            `json:"status"`
+	Detail string         `json:"detail,omitempty"`
+	Errors []ProblemField `json:"errors,omitempty"`
+}
+
+// ProblemField 某一个字段的错误
+type ProblemField struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule,omitempty"`
+	Source  string `json:"source,omitempty"`
+	Message string `json:"message"`
+}
+
+// RespProblem 把 Bind 和 BindJson 返回的错误渲染成 application/problem+json 格式的响应
+// ValidationErrors 使用 ServerWithValidationStatus 配置的状态码，默认是 422；UnsupportedMediaTypeError 是 415；其余的错误都是 400
+func (c *Context) RespProblem(err error) {
+	p := Problem{Type: "about:blank", Status: http.StatusBadRequest}
+	var ves ValidationErrors
+	var bes BindErrors
+	var ume *UnsupportedMediaTypeError
+	switch {
+	case errors.As(err, &ume):
+		p.Status = http.StatusUnsupportedMediaType
+		p.Detail = ume.Error()
+	case errors.As(err, &ves):
+		p.Status = c.validationStatus
+		if p.Status == 0 {
+			p.Status = http.StatusUnprocessableEntity
+		}
+		p.Detail = "请求参数校验失败"
+		for _, e := range ves {
+			p.Errors = append(p.Errors, ProblemField{Field: e.Field, Rule: e.Rule, Message: e.Message})
+		}
+	case errors.As(err, &bes):
+		p.Detail = "请求参数格式错误"
+		for _, e := range bes {
+			p.Errors = append(p.Errors, ProblemField{Field: e.Field, Source: e.Source, Message: e.Err.Error()})
+		}
+	default:
+		p.Detail = err.Error()
+	}
+	p.Title = http.StatusText(p.Status)
+	data, _ := json.Marshal(p)
+	c.resp(p.Status, "application/problem+json", data)
+}
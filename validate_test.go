@@ -0,0 +1,300 @@
+package web
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type validateAddress struct {
+	City string `validate:"required"`
+	Zip  string `validate:"omitempty,len=6,regex=^[0-9]+$"`
+}
+
+type validateBase struct {
+	ID int64 `validate:"required,min=1"`
+}
+
+type validateUser struct {
+	validateBase
+	Name    string           `validate:"required,min=2,max=8"`
+	Email   string           `validate:"omitempty,email"`
+	Role    string           `validate:"omitempty,oneof=admin user"`
+	Age     *int             `validate:"required,max=150"`
+	Tags    []string         `validate:"max=2"`
+	Code    string           `validate:"omitempty,regex=^[a-z]{2,3}$"`
+	Address *validateAddress `validate:"required"`
+	Others  []validateAddress
+	Labels  map[string]string `validate:"omitempty,len=1"`
+	Score   float64           `validate:"omitempty,min=0.5"`
+	Page    int               `validate:"min=1"`
+	Limit   *int              `validate:"min=1"`
+}
+
+func TestTagValidator_Validate(t *testing.T) {
+	age, tooOld := 18, 200
+	valid := func() validateUser {
+		return validateUser{
+			validateBase: validateBase{ID: 1},
+			Name:         "tom",
+			Email:        "tom@example.com",
+			Role:         "admin",
+			Age:          &age,
+			Tags:         []string{"a"},
+			Code:         "ab",
+			Address:      &validateAddress{City: "sz", Zip: "518000"},
+			Others:       []validateAddress{{City: "gz"}},
+			Labels:       map[string]string{"a": "b"},
+			Score:        1,
+			Page:         1,
+		}
+	}
+	testCases := []struct {
+		name   string
+		modify func(u *validateUser)
+
+		// wantErrs 字段:规则
+		wantErrs []string
+	}{
+		{
+			name:   "valid",
+			modify: func(u *validateUser) {},
+		},
+		{
+			name: "optional zero values",
+			modify: func(u *validateUser) {
+				u.Email, u.Role, u.Tags, u.Code, u.Others, u.Labels, u.Score = "", "", nil, "", nil, nil, 0
+			},
+		},
+		{
+			name: "zero values without omitempty",
+			modify: func(u *validateUser) {
+				zero := 0
+				u.Page, u.Limit = 0, &zero
+			},
+			wantErrs: []string{"Page:min", "Limit:min"},
+		},
+		{
+			name: "required",
+			modify: func(u *validateUser) {
+				u.ID, u.Name, u.Age, u.Address = 0, "", nil, nil
+			},
+			wantErrs: []string{"ID:required", "Name:required", "Age:required", "Address:required"},
+		},
+		{
+			name: "rules",
+			modify: func(u *validateUser) {
+				u.Name = "汤姆汤姆汤姆汤姆汤"
+				u.Email = "Tom <tom@example.com>"
+				u.Role = "root"
+				u.Age = &tooOld
+				u.Tags = []string{"a", "b", "c"}
+				u.Code = "a,b"
+				u.Labels = map[string]string{"a": "b", "c": "d"}
+				u.Score = 0.1
+			},
+			wantErrs: []string{"Name:max", "Email:email", "Role:oneof", "Age:max", "Tags:max", "Code:regex", "Labels:len", "Score:min"},
+		},
+		{
+			name: "nested",
+			modify: func(u *validateUser) {
+				u.ID = -1
+				u.Address.Zip = "51800a"
+				u.Others = []validateAddress{{City: "gz"}, {Zip: "1"}}
+			},
+			wantErrs: []string{"ID:min", "Address.Zip:regex", "Others[1].City:required", "Others[1].Zip:len"},
+		},
+	}
+	v := NewTagValidator()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := valid()
+			tc.modify(&u)
+			err := v.Validate(&u)
+			if len(tc.wantErrs) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var errs ValidationErrors
+			require.True(t, errors.As(err, &errs))
+			got := make([]string, len(errs))
+			for i, e := range errs {
+				got[i] = e.Field + ":" + e.Rule
+			}
+			assert.Equal(t, tc.wantErrs, got)
+		})
+	}
+}
+
+func TestTagValidator_Message(t *testing.T) {
+	u := struct {
+		Name string `validate:"min=2"`
+		Age  int    `validate:"max=150"`
+		Role string `validate:"oneof=admin user"`
+	}{Name: "t", Age: 200, Role: "root"}
+	err := NewTagValidator().Validate(u)
+	assert.EqualError(t, err, "web: 字段 Name 校验失败，长度不能小于 2\n"+
+		"web: 字段 Age 校验失败，值不能大于 150\n"+
+		"web: 字段 Role 校验失败，必须是 [admin, user] 中的一个")
+}
+
+func TestTagValidator_InvalidTag(t *testing.T) {
+	testCases := []struct {
+		name    string
+		val     any
+		wantErr string
+	}{
+		{
+			name: "unknown rule",
+			val: struct {
+				Name string `validate:"required,unknown"`
+			}{},
+			wantErr: "web: 字段 Name 的校验规则错误 未知的校验规则 [unknown]",
+		},
+		{
+			name: "required with omitempty",
+			val: struct {
+				Name string `validate:"required,omitempty,min=2"`
+			}{},
+			wantErr: "web: 字段 Name 的校验规则错误 required 和 omitempty 不能同时使用",
+		},
+		{
+			name: "bad number",
+			val: struct {
+				Name string `validate:"min=a"`
+			}{},
+			wantErr: "web: 字段 Name 的校验规则错误 [min=a] 的参数必须是数字",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := NewTagValidator().Validate(tc.val)
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestContext_RespProblem(t *testing.T) {
+	type createUser struct {
+		Name string `json:"name" validate:"required"`
+		Page int    `query:"page" validate:"max=100"`
+	}
+	handler := func(ctx *Context) {
+		var req createUser
+		if err := ctx.BindJson(&req); err != nil {
+			ctx.RespProblem(err)
+			return
+		}
+		if err := ctx.Bind(&req); err != nil {
+			ctx.RespProblem(err)
+			return
+		}
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(req.Name)
+	}
+	testCases := []struct {
+		name string
+		opts []HTTPServerOption
+		url  string
+		body string
+
+		wantCode int
+		wantResp string
+	}{
+		{
+			name:     "ok",
+			url:      "/user?page=1",
+			body:     `{"name":"tom"}`,
+			wantCode: http.StatusOK,
+			wantResp: "tom",
+		},
+		{
+			name:     "validation",
+			url:      "/user",
+			body:     `{}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantResp: `{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"请求参数校验失败","errors":[{"field":"Name","rule":"required","message":"不能为空"}]}`,
+		},
+		{
+			name:     "validation status",
+			opts:     []HTTPServerOption{ServerWithValidationStatus(http.StatusBadRequest)},
+			url:      "/user?page=101",
+			body:     `{"name":"tom"}`,
+			wantCode: http.StatusBadRequest,
+			wantResp: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"请求参数校验失败","errors":[{"field":"Page","rule":"max","message":"值不能大于 100"}]}`,
+		},
+		{
+			name:     "bind error",
+			url:      "/user?page=abc",
+			body:     `{"name":"tom"}`,
+			wantCode: http.StatusBadRequest,
+			wantResp: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"请求参数格式错误","errors":[{"field":"Page","source":"query","message":"strconv.ParseInt: parsing \"abc\": invalid syntax"}]}`,
+		},
+		{
+			name:     "bad json",
+			url:      "/user",
+			body:     `{`,
+			wantCode: http.StatusBadRequest,
+			wantResp: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"unexpected EOF"}`,
+		},
+		{
+			name:     "without validator",
+			opts:     []HTTPServerOption{ServerWithValidator(nil)},
+			url:      "/user",
+			body:     `{}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "custom validator",
+			opts: []HTTPServerOption{ServerWithValidator(ValidatorFunc(func(v any) error {
+				return ValidationErrors{{Field: "Name", Rule: "unique", Message: "已经存在"}}
+			}))},
+			url:      "/user",
+			body:     `{"name":"tom"}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantResp: `{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"请求参数校验失败","errors":[{"field":"Name","rule":"unique","message":"已经存在"}]}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHttpServer(append([]HTTPServerOption{ServerWithValidator(NewTagValidator())}, tc.opts...)...)
+			h.Post("/user", handler)
+			req := httptest.NewRequest(http.MethodPost, tc.url, strings.NewReader(tc.body))
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+			if tc.wantCode != http.StatusOK {
+				assert.Equal(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestContext_BindJson_ForeignTags(t *testing.T) {
+	// 默认不做校验，其它校验库的标签不会影响 BindJson
+	type user struct {
+		Name string `json:"name" validate:"required"`
+		Age  int    `json:"age" validate:"gte=0"`
+	}
+	h := NewHttpServer()
+	h.Post("/user", func(ctx *Context) {
+		var u user
+		if err := ctx.BindJson(&u); err != nil {
+			ctx.RespProblem(err)
+			return
+		}
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(strconv.Itoa(u.Age))
+	})
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"age":18}`))
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "18", recorder.Body.String())
+}